@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 )
 
 var (
@@ -24,9 +25,92 @@ const (
 		language string,
 		voice_token string
 	);
+	create table if not exists channel (
+		id integer not null primary key,
+		discord_id string not null,
+		language string,
+		voice_token string
+	);
+	create table if not exists auto_join (
+		id integer not null primary key,
+		guild_id string not null,
+		voice_channel_id string not null unique,
+		text_channel_id string not null
+	);
+	create table if not exists session (
+		id integer not null primary key,
+		bot_id string not null,
+		guild_id string not null,
+		voice_channel_id string not null,
+		text_channel_id string not null,
+		text_channel_ids string not null,
+		unique(bot_id, guild_id)
+	);
 	`
 )
 
+// GuildSetting is a column of guild table which can be configured per guild
+type GuildSetting string
+
+const (
+	GuildJoinTemplate   GuildSetting = "join_template"   // template of announcement when a member joins VC
+	GuildLeaveTemplate  GuildSetting = "leave_template"  // template of announcement when a member leaves VC
+	GuildStreamTemplate GuildSetting = "stream_template" // template of announcement when a member starts streaming
+	GuildEditBehavior   GuildSetting = "edit_behavior"   // how to treat queued messages edited before read
+	GuildQueueSize      GuildSetting = "queue_size"      // max number of queued messages
+	GuildOverflow       GuildSetting = "overflow"        // what to do when a message is posted while the queue is full
+	GuildMaxAge         GuildSetting = "max_age"         // seconds after which queued messages are skipped
+	GuildSchedule       GuildSetting = "schedule"        // order to read queued messages
+	GuildMaxPerAuthor   GuildSetting = "max_per_author"  // max number of queued messages of a member
+	GuildSystemPriority GuildSetting = "system_priority" // priority of speech by the bot over messages
+	GuildCoalesceMillis GuildSetting = "coalesce_ms"     // max interval in milliseconds of messages of a member read at once
+	GuildMaxRate        GuildSetting = "max_rate"        // ceiling in percent of speaking rate raised when messages are waiting
+	GuildTextCommands   GuildSetting = "text_commands"   // whether commands in text messages such as !hi are enabled
+	GuildPrefix         GuildSetting = "prefix"          // prefix of text commands. messages starting with it are not read
+	GuildIgnorePrefixes GuildSetting = "ignore_prefixes" // space separated prefixes of messages not read
+	GuildIgnoreBots     GuildSetting = "ignore_bots"     // whether messages of bots and webhooks are ignored
+	GuildUILang         GuildSetting = "ui_lang"         // language of messages of the bot. "auto" follows the preferred locale of the guild
+	GuildAdminRoles     GuildSetting = "admin_roles"     // space separated IDs of roles which can run admin commands
+	GuildIdleTimeout    GuildSetting = "idle_timeout"    // seconds to wait before leaving VC after all members left
+	GuildFollowUser     GuildSetting = "follow_user"     // ID of the member the bot follows when they move to another VC
+	GuildShutdownNotice GuildSetting = "shutdown_notice" // how to tell members that the bot leaves VC for shutdown
+)
+
+// GuildSettings is the list of all settings in the order shown to users
+var GuildSettings = []GuildSetting{
+	GuildJoinTemplate,
+	GuildLeaveTemplate,
+	GuildStreamTemplate,
+	GuildEditBehavior,
+	GuildQueueSize,
+	GuildOverflow,
+	GuildMaxAge,
+	GuildSchedule,
+	GuildMaxPerAuthor,
+	GuildSystemPriority,
+	GuildCoalesceMillis,
+	GuildMaxRate,
+	GuildTextCommands,
+	GuildPrefix,
+	GuildIgnorePrefixes,
+	GuildIgnoreBots,
+	GuildUILang,
+	GuildAdminRoles,
+	GuildIdleTimeout,
+	GuildFollowUser,
+	GuildShutdownNotice,
+}
+
+// ParseGuildSetting returns GuildSetting named name
+func ParseGuildSetting(name string) (GuildSetting, bool) {
+	for _, s := range GuildSettings {
+		if string(s) == name {
+			return s, true
+		}
+	}
+	return "", false
+}
+
 // Init creates tables if not exists
 func Init() {
 	var err error
@@ -35,11 +119,88 @@ func Init() {
 		log.Fatal("failed to open db: ", err)
 	}
 
-	if _, err := db.Exec(createStmt); err != nil {
+	if err := migrate(); err != nil {
 		log.Fatal("failed to initialize db: ", err)
 	}
 }
 
+// migrate creates tables and adds columns added after the table was created
+func migrate() error {
+	if _, err := db.Exec(createStmt); err != nil {
+		return fmt.Errorf("error create tables: %w", err)
+	}
+
+	cols, err := columns("session")
+	if err != nil {
+		return err
+	}
+	if !cols["bot_id"] {
+		if err := migrateSession(); err != nil {
+			return err
+		}
+	}
+
+	if cols, err = columns("guild"); err != nil {
+		return err
+	}
+	for _, s := range GuildSettings {
+		if cols[string(s)] {
+			continue
+		}
+		if _, err := db.Exec(`alter table guild add column ` + string(s) + ` string`); err != nil {
+			return fmt.Errorf("error add column %s to guild: %w", s, err)
+		}
+	}
+	return nil
+}
+
+// migrateSession rebuilds session table saved before multiple bots are supported, which is unique by guild.
+// sessions are kept with empty bot_id until ClaimSessions gives them to a bot
+func migrateSession() error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error begin migration of session: %w", err)
+	}
+	defer tx.Rollback()
+	stmts := []string{
+		`alter table session rename to session_old`,
+		createStmt,
+		`insert into session(bot_id, guild_id, voice_channel_id, text_channel_id, text_channel_ids)
+			select '', guild_id, voice_channel_id, text_channel_id, text_channel_ids from session_old`,
+		`drop table session_old`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("error migrate session: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error commit migration of session: %w", err)
+	}
+	return nil
+}
+
+// columns returns names of columns of the table
+func columns(table string) (map[string]bool, error) {
+	cols := map[string]bool{}
+	rows, err := db.Query(`select name from pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, fmt.Errorf("error get columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("error scan column name of %s: %w", table, err)
+		}
+		cols[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error get columns of %s: %w", table, err)
+	}
+	return cols, nil
+}
+
 // Close closes db
 func Close() {
 	if err := db.Close(); err != nil {
@@ -49,27 +210,42 @@ func Close() {
 
 // UpsertUserVoiceToken updates or inserts user's voice_token
 func UpsertUserVoiceToken(userID, voiceToken string) error {
-	return upsertImpl(userID, voiceToken, "voice_token")
+	return upsertImpl("user", userID, voiceToken, "voice_token")
 }
 
 // UpsertUserLanguage updates or inserts user's language
 func UpsertUserLanguage(userID, voiceToken string) error {
-	return upsertImpl(userID, voiceToken, "language")
+	return upsertImpl("user", userID, voiceToken, "language")
+}
+
+// UpsertChannelVoiceToken updates or inserts channel's voice_token overriding members' ones
+func UpsertChannelVoiceToken(channelID, voiceToken string) error {
+	return upsertImpl("channel", channelID, voiceToken, "voice_token")
+}
+
+// UpsertChannelLanguage updates or inserts channel's language overriding members' ones
+func UpsertChannelLanguage(channelID, language string) error {
+	return upsertImpl("channel", channelID, language, "language")
+}
+
+// UpsertGuildSetting updates or inserts guild's setting
+func UpsertGuildSetting(guildID string, s GuildSetting, val string) error {
+	return upsertImpl("guild", guildID, val, string(s))
 }
 
-func upsertImpl(userID, val, col string) error {
+func upsertImpl(table, discordID, val, col string) error {
 	var res string
-	err := db.QueryRow(`select discord_id from user where discord_id = ? limit 1`, userID).Scan(&res)
+	err := db.QueryRow(`select discord_id from `+table+` where discord_id = ? limit 1`, discordID).Scan(&res)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("error select user by discord_id: %w", err)
+		return fmt.Errorf("error select "+table+" by discord_id: %w", err)
 	} else if errors.Is(err, sql.ErrNoRows) {
-		if _, err := db.Exec(`insert into user(discord_id, `+col+`) values(?, ?)`, userID, val); err != nil {
-			return fmt.Errorf("error insert new user: %w", err)
+		if _, err := db.Exec(`insert into `+table+`(discord_id, `+col+`) values(?, ?)`, discordID, val); err != nil {
+			return fmt.Errorf("error insert new "+table+": %w", err)
 		}
 		return nil
 	} else {
-		if _, err := db.Exec(`update user set `+col+` = ? where discord_id = ?`, val, userID); err != nil {
-			return fmt.Errorf("error update user: %w", err)
+		if _, err := db.Exec(`update `+table+` set `+col+` = ? where discord_id = ?`, val, discordID); err != nil {
+			return fmt.Errorf("error update "+table+": %w", err)
 		}
 		return nil
 	}
@@ -77,22 +253,160 @@ func upsertImpl(userID, val, col string) error {
 
 // GetUserVoiceToken get user's voice_token
 func GetUserVoiceToken(userID string) (string, error) {
-	return getImpl(userID, "voice_token")
+	return getImpl("user", userID, "voice_token")
 }
 
 // GetUserLanguage get user's language
 func GetUserLanguage(userID string) (string, error) {
-	return getImpl(userID, "language")
+	return getImpl("user", userID, "language")
 }
 
-func getImpl(userID, col string) (string, error) {
-	var res string
-	err := db.QueryRow(`select `+col+` from user where discord_id = ? limit 1`, userID).Scan(&res)
+// GetChannelVoiceToken get channel's voice_token. empty string is returned if not set
+func GetChannelVoiceToken(channelID string) (string, error) {
+	return getImpl("channel", channelID, "voice_token")
+}
+
+// GetChannelLanguage get channel's language. empty string is returned if not set
+func GetChannelLanguage(channelID string) (string, error) {
+	return getImpl("channel", channelID, "language")
+}
+
+// GetGuildSetting get guild's setting. empty string is returned if not set
+func GetGuildSetting(guildID string, s GuildSetting) (string, error) {
+	return getImpl("guild", guildID, string(s))
+}
+
+func getImpl(table, discordID, col string) (string, error) {
+	var res sql.NullString
+	err := db.QueryRow(`select `+col+` from `+table+` where discord_id = ? limit 1`, discordID).Scan(&res)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return "", fmt.Errorf("error get column of "+col+" by select user by discord_id: %w", err)
+		return "", fmt.Errorf("error get column of "+col+" by select "+table+" by discord_id: %w", err)
 	} else if errors.Is(err, sql.ErrNoRows) {
 		return "", nil
 	} else {
-		return res, nil
+		return res.String, nil
+	}
+}
+
+// AutoJoin is a rule to join the voice channel and read the text channel when a member joins the voice channel
+type AutoJoin struct {
+	VoiceChannelID string
+	TextChannelID  string
+}
+
+// UpsertAutoJoin updates or inserts the auto join rule of the voice channel
+func UpsertAutoJoin(guildID, voiceChannelID, textChannelID string) error {
+	_, err := db.Exec(`insert into auto_join(guild_id, voice_channel_id, text_channel_id) values(?, ?, ?)
+		on conflict(voice_channel_id) do update set text_channel_id = excluded.text_channel_id`,
+		guildID, voiceChannelID, textChannelID)
+	if err != nil {
+		return fmt.Errorf("error upsert auto_join: %w", err)
+	}
+	return nil
+}
+
+// DeleteAutoJoin deletes the auto join rule of the voice channel. false is returned if it does not exist
+func DeleteAutoJoin(voiceChannelID string) (bool, error) {
+	res, err := db.Exec(`delete from auto_join where voice_channel_id = ?`, voiceChannelID)
+	if err != nil {
+		return false, fmt.Errorf("error delete auto_join: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error delete auto_join: %w", err)
+	}
+	return n > 0, nil
+}
+
+// DeleteAutoJoinsOfChannel deletes auto join rules of the voice or text channel
+func DeleteAutoJoinsOfChannel(channelID string) error {
+	if _, err := db.Exec(`delete from auto_join where voice_channel_id = ? or text_channel_id = ?`, channelID, channelID); err != nil {
+		return fmt.Errorf("error delete auto_join: %w", err)
+	}
+	return nil
+}
+
+// GetAutoJoin get text channel to read when a member joins the voice channel. empty string is returned if not set
+func GetAutoJoin(voiceChannelID string) (string, error) {
+	var res string
+	err := db.QueryRow(`select text_channel_id from auto_join where voice_channel_id = ?`, voiceChannelID).Scan(&res)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("error select auto_join by voice_channel_id: %w", err)
+	}
+	return res, nil
+}
+
+// ListAutoJoins get all auto join rules of the guild
+func ListAutoJoins(guildID string) ([]AutoJoin, error) {
+	rows, err := db.Query(`select voice_channel_id, text_channel_id from auto_join where guild_id = ? order by id`, guildID)
+	if err != nil {
+		return nil, fmt.Errorf("error select auto_join by guild_id: %w", err)
+	}
+	defer rows.Close()
+	res := []AutoJoin{}
+	for rows.Next() {
+		var a AutoJoin
+		if err := rows.Scan(&a.VoiceChannelID, &a.TextChannelID); err != nil {
+			return nil, fmt.Errorf("error scan auto_join: %w", err)
+		}
+		res = append(res, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error select auto_join by guild_id: %w", err)
+	}
+	return res, nil
+}
+
+// Session is a voice channel the bot is reading text channels in
+type Session struct {
+	BotID          string
+	GuildID        string
+	VoiceChannelID string
+	TextChannelID  string   // channel where the session is started
+	TextChannelIDs []string // all channels read
+}
+
+// UpsertSession updates or inserts the session of the bot on the guild
+func UpsertSession(s Session) error {
+	_, err := db.Exec(`insert into session(bot_id, guild_id, voice_channel_id, text_channel_id, text_channel_ids) values(?, ?, ?, ?, ?)
+		on conflict(bot_id, guild_id) do update set voice_channel_id = excluded.voice_channel_id,
+			text_channel_id = excluded.text_channel_id, text_channel_ids = excluded.text_channel_ids`,
+		s.BotID, s.GuildID, s.VoiceChannelID, s.TextChannelID, strings.Join(s.TextChannelIDs, " "))
+	if err != nil {
+		return fmt.Errorf("error upsert session: %w", err)
+	}
+	return nil
+}
+
+// DeleteSession deletes the session of the bot on the guild
+func DeleteSession(botID, guildID string) error {
+	if _, err := db.Exec(`delete from session where bot_id = ? and guild_id = ?`, botID, guildID); err != nil {
+		return fmt.Errorf("error delete session: %w", err)
+	}
+	return nil
+}
+
+// ClaimSessions gives sessions saved before multiple bots are supported to the bot
+func ClaimSessions(botID string) error {
+	if _, err := db.Exec(`update session set bot_id = ? where bot_id = ''`, botID); err != nil {
+		return fmt.Errorf("error claim sessions: %w", err)
+	}
+	return nil
+}
+
+// GetSession get the session of the bot on the guild. nil is returned if not exists
+func GetSession(botID, guildID string) (*Session, error) {
+	s := Session{BotID: botID, GuildID: guildID}
+	var ids string
+	err := db.QueryRow(`select voice_channel_id, text_channel_id, text_channel_ids from session where bot_id = ? and guild_id = ?`, botID, guildID).
+		Scan(&s.VoiceChannelID, &s.TextChannelID, &ids)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error select session by bot_id and guild_id: %w", err)
 	}
+	s.TextChannelIDs = strings.Fields(ids)
+	return &s, nil
 }
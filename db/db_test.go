@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"reflect"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -50,3 +51,229 @@ func TestUpsertUserVoiceToken(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestGuildSetting(t *testing.T) {
+	os.Remove("./test.db")
+	if db != nil {
+		db.Close()
+	}
+
+	var err error
+	db, err = sql.Open("sqlite3", "./test.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+		os.Remove("./test.db")
+	}()
+
+	if err := migrate(); err != nil {
+		log.Fatal(err)
+	}
+	// migrate must be idempotent
+	if err := migrate(); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if v, err := GetGuildSetting("123", GuildJoinTemplate); !(v == "" && err == nil) {
+		t.Log(v, err)
+		t.FailNow()
+	}
+	if err := UpsertGuildSetting("123", GuildJoinTemplate, "{name} joined"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if v, err := GetGuildSetting("123", GuildJoinTemplate); !(v == "{name} joined" && err == nil) {
+		t.Log(v, err)
+		t.FailNow()
+	}
+	// other columns of the same row are not set yet
+	if v, err := GetGuildSetting("123", GuildLeaveTemplate); !(v == "" && err == nil) {
+		t.Log(v, err)
+		t.FailNow()
+	}
+}
+
+func TestAutoJoin(t *testing.T) {
+	os.Remove("./test.db")
+	if db != nil {
+		db.Close()
+	}
+
+	var err error
+	db, err = sql.Open("sqlite3", "./test.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+		os.Remove("./test.db")
+	}()
+
+	if err := migrate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := UpsertAutoJoin("1", "10", "100"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if err := UpsertAutoJoin("1", "11", "101"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// the rule of the same voice channel is replaced
+	if err := UpsertAutoJoin("1", "10", "102"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if tc, err := GetAutoJoin("10"); !(tc == "102" && err == nil) {
+		t.Log(tc, err)
+		t.FailNow()
+	}
+	if as, err := ListAutoJoins("1"); !(err == nil && reflect.DeepEqual(as, []AutoJoin{{"10", "102"}, {"11", "101"}})) {
+		t.Log(as, err)
+		t.FailNow()
+	}
+	if ok, err := DeleteAutoJoin("10"); !(ok && err == nil) {
+		t.Log(ok, err)
+		t.FailNow()
+	}
+	if ok, err := DeleteAutoJoin("10"); !(!ok && err == nil) {
+		t.Log(ok, err)
+		t.FailNow()
+	}
+	if tc, err := GetAutoJoin("10"); !(tc == "" && err == nil) {
+		t.Log(tc, err)
+		t.FailNow()
+	}
+	// rules reading a deleted text channel are also deleted
+	if err := UpsertAutoJoin("1", "12", "101"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if err := DeleteAutoJoinsOfChannel("101"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if as, err := ListAutoJoins("1"); !(err == nil && len(as) == 0) {
+		t.Log(as, err)
+		t.FailNow()
+	}
+}
+
+func TestSession(t *testing.T) {
+	os.Remove("./test.db")
+	if db != nil {
+		db.Close()
+	}
+
+	var err error
+	db, err = sql.Open("sqlite3", "./test.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+		os.Remove("./test.db")
+	}()
+
+	if err := migrate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if s, err := GetSession("b1", "1"); !(s == nil && err == nil) {
+		t.Log(s, err)
+		t.FailNow()
+	}
+	if err := UpsertSession(Session{"b1", "1", "10", "100", []string{"100"}}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// the session of the same bot and guild is replaced
+	want := Session{"b1", "1", "11", "100", []string{"100", "101"}}
+	if err := UpsertSession(want); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// other bots have their own sessions on the same guild
+	other := Session{"b2", "1", "12", "102", []string{"102"}}
+	if err := UpsertSession(other); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if s, err := GetSession("b1", "1"); !(err == nil && reflect.DeepEqual(s, &want)) {
+		t.Log(s, err)
+		t.FailNow()
+	}
+	if err := DeleteSession("b1", "1"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if s, err := GetSession("b2", "1"); !(err == nil && reflect.DeepEqual(s, &other)) {
+		t.Log(s, err)
+		t.FailNow()
+	}
+	if s, err := GetSession("b1", "1"); !(s == nil && err == nil) {
+		t.Log(s, err)
+		t.FailNow()
+	}
+}
+
+func TestMigrateSession(t *testing.T) {
+	os.Remove("./test.db")
+	if db != nil {
+		db.Close()
+	}
+
+	var err error
+	db, err = sql.Open("sqlite3", "./test.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+		os.Remove("./test.db")
+	}()
+
+	// session table saved before multiple bots are supported
+	_, err = db.Exec(`create table session (
+		id integer not null primary key,
+		guild_id string not null unique,
+		voice_channel_id string not null,
+		text_channel_id string not null,
+		text_channel_ids string not null
+	);
+	insert into session(guild_id, voice_channel_id, text_channel_id, text_channel_ids) values('1', '10', '100', '100 101')`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := migrate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := ClaimSessions("b1"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	want := Session{"b1", "1", "10", "100", []string{"100", "101"}}
+	if s, err := GetSession("b1", "1"); !(err == nil && reflect.DeepEqual(s, &want)) {
+		t.Log(s, err)
+		t.FailNow()
+	}
+	// sessions of other bots can be saved on the same guild after migration
+	if err := UpsertSession(Session{"b2", "1", "11", "102", []string{"102"}}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// migration is done once
+	if err := migrate(); err != nil {
+		log.Fatal(err)
+	}
+	if s, err := GetSession("b1", "1"); !(err == nil && reflect.DeepEqual(s, &want)) {
+		t.Log(s, err)
+		t.FailNow()
+	}
+}
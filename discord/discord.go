@@ -1,83 +1,189 @@
 package discord
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/i18n"
 	"github.com/tubo28/yomiage/tts"
+	"github.com/tubo28/yomiage/worker"
 )
 
 var (
-	discordToken string = os.Getenv("DISCORD_TOKEN")
-	dg           *discordgo.Session
+	// tokens of bots run by this process. DISCORD_TOKENS is a comma separated list for multiple bots
+	discordTokens []string = splitTokens(os.Getenv("DISCORD_TOKENS"), os.Getenv("DISCORD_TOKEN"))
+	bots          []*bot   // in the order of discordTokens
 )
 
 func init() {
-	if discordToken == "" {
+	if len(discordTokens) == 0 {
 		log.Fatal("no discord token is given")
 	}
 }
 
-// Init create and starts Discord client
+func splitTokens(tokens, token string) []string {
+	res := []string{}
+	for _, t := range strings.Split(tokens, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			res = append(res, t)
+		}
+	}
+	if len(res) == 0 && token != "" {
+		res = append(res, token)
+	}
+	return res
+}
+
+// bot is a bot identity and sessions of its shards
+type bot struct {
+	id         string                     // user ID of the bot
+	shardCount int                        // total number of shards of the bot
+	shards     map[int]*discordgo.Session // maps shard ID to the session. only shards run by this process
+}
+
+// identifyInterval is the interval to open sessions of shards, which Discord limits
+const identifyInterval = 5 * time.Second
+
+// Init create and starts Discord clients of shards of all bots
 func Init() {
-	var err error
-	dg, err = discordgo.New("Bot " + discordToken)
+	for _, token := range discordTokens {
+		b, err := openBot(token)
+		if err != nil {
+			log.Fatal("error start bot: ", err)
+		}
+		bots = append(bots, b)
+	}
+}
+
+// openBot opens sessions of shards of the bot run by this process
+func openBot(token string) (*bot, error) {
+	count, ids, err := shardConfig(token)
 	if err != nil {
-		fmt.Println("Error creating Discord session: ", err)
-		return
+		return nil, fmt.Errorf("invalid shard config: %w", err)
 	}
 
-	dg.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentsGuildVoiceStates)
+	b := &bot{shardCount: count, shards: map[int]*discordgo.Session{}}
+	for i, id := range ids {
+		if i > 0 {
+			time.Sleep(identifyInterval)
+		}
+		s, err := discordgo.New("Bot " + token)
+		if err != nil {
+			return nil, fmt.Errorf("error creating Discord session: %w", err)
+		}
+		s.ShardID = id
+		s.ShardCount = count
+		s.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentsGuildVoiceStates | discordgo.IntentsMessageContent)
 
-	if err := dg.Open(); err != nil {
-		fmt.Println("Error opening Discord session: ", err)
+		if err := s.Open(); err != nil {
+			return nil, fmt.Errorf("error opening Discord session of shard %d: %w", id, err)
+		}
+		b.id = s.State.User.ID
+		b.shards[id] = s
 	}
+	log.Printf("bot %s runs shards %v of %d", b.id, ids, count)
+	return b, nil
 }
 
-// Close closes discordgo client
+// Close closes discordgo clients of all shards of all bots
 func Close() {
-	if err := dg.Close(); err != nil {
-		log.Print(err.Error())
+	for _, b := range bots {
+		for _, s := range b.shards {
+			if err := s.Close(); err != nil {
+				log.Print(err.Error())
+			}
+		}
 	}
 }
 
-// AddHandler adds handler h to global discord client
+// BotIDs returns user IDs of all bots in the order of the tokens
+func BotIDs() []string {
+	ids := []string{}
+	for _, b := range bots {
+		ids = append(ids, b.id)
+	}
+	return ids
+}
+
+// Session returns discord client of the bot for the shard of the guild.
+// nil is returned if the bot or the shard is not run by this process
+func Session(botID, guildID string) *discordgo.Session {
+	for _, b := range bots {
+		if b.id == botID {
+			return b.shards[shardOf(guildID, b.shardCount)]
+		}
+	}
+	return nil
+}
+
+// Sessions returns discord clients of all shards of all bots run by this process
+func Sessions() []*discordgo.Session {
+	res := []*discordgo.Session{}
+	for _, b := range bots {
+		for _, s := range b.shards {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// AddHandler adds handler h to discord clients of all shards of all bots
 func AddHandler(h interface{}) {
-	dg.AddHandler(h)
+	for _, b := range bots {
+		for _, s := range b.shards {
+			s.AddHandler(h)
+		}
+	}
+}
+
+// RegisterCommands overwrites application commands of all bots with cmds
+func RegisterCommands(cmds []*discordgo.ApplicationCommand) {
+	for _, b := range bots {
+		// commands are global, so registering on any shard is enough
+		for _, s := range b.shards {
+			if _, err := s.ApplicationCommandBulkOverwrite(b.id, "", cmds); err != nil {
+				log.Print("error register application commands of bot ", b.id, ": ", err)
+			}
+			break
+		}
+	}
 }
 
 // JoinVC adds the bot to guild
-func JoinVC(s *discordgo.Session, guildID, vcID string) (msg string) {
+func JoinVC(s *discordgo.Session, guildID, vcID string, lang i18n.Lang) (msg string, err error) {
 	// ok should not be true because use state is also checked in textChannelIDs
-	if conn, ok := dg.VoiceConnections[guildID]; ok {
+	if conn, ok := voiceConnection(s.State.User.ID, guildID); ok {
 		// todo: force move here?
 		if conn.ChannelID == vcID {
 			log.Printf("bot is already joining target voice channel %s guild %s", conn.ChannelID, conn.GuildID)
-			// Already working. Something is wrong.
-			return "すでにボイスチャンネルにいます。何かがおかしいです。"
+			return i18n.T(lang, i18n.JoinAlreadyHere), nil
+		}
+		// the connection is left without session, e.g. reconnected after the session is stopped
+		log.Printf("bot is already joining other voice channel %s guild %s, move to %s", conn.ChannelID, conn.GuildID, vcID)
+		if err := conn.ChangeChannel(vcID, false, true); err != nil {
+			return "", fmt.Errorf("failed to move from channel %s to %s on guild %s: %w", conn.ChannelID, vcID, guildID, err)
 		}
-		log.Printf("bot is already joining other voice channel %s guild %s", conn.ChannelID, conn.GuildID)
-		// Already working on other channel. Something is wrong.
-		return "すでに他のボイスチャンネルにいます。何かがおかしいです。"
+		return i18n.T(lang, i18n.Joined), nil
 	}
 
 	if _, err := s.ChannelVoiceJoin(guildID, vcID, false, true); err != nil {
-		log.Printf("failed to join channel %s on guild %s", vcID, guildID)
-		return
+		return "", fmt.Errorf("failed to join channel %s on guild %s: %w", vcID, guildID, err)
 	}
 
-	return "読み上げます。" // I read text here
+	return i18n.T(lang, i18n.Joined), nil
 }
 
 // LeaveVC removes the bot from guild
-func LeaveVC(guildID string) (msg string) {
-	conn, ok := dg.VoiceConnections[guildID]
+func LeaveVC(botID, guildID string, lang i18n.Lang) (msg string) {
+	conn, ok := voiceConnection(botID, guildID)
 	// ok should not be false because use state is also checked in textChannelIDs
 	if !ok {
-		// Bot is not joining any of this server's voice channel
-		return "このサーバーにbotが参加しているボイスチャンネルがありません。何かがおかしいです。"
+		return i18n.T(lang, i18n.LeaveNotJoined)
 	}
 
 	if err := conn.Disconnect(); err != nil {
@@ -85,7 +191,7 @@ func LeaveVC(guildID string) (msg string) {
 		return
 	}
 
-	return "さようなら" // Bye
+	return i18n.T(lang, i18n.Left)
 }
 
 // VoiceState return discordgo.VoiceState of the guild on the user
@@ -102,60 +208,84 @@ func VoiceState(s *discordgo.Session, userID, guildID string) (*discordgo.VoiceS
 	return nil, nil
 }
 
-func voiceConnection(guildID string) (*discordgo.VoiceConnection, bool) {
-	conn, ok := dg.VoiceConnections[guildID]
+// voiceConnection returns the voice connection of the bot on the guild in the session of its shard
+func voiceConnection(botID, guildID string) (*discordgo.VoiceConnection, bool) {
+	s := Session(botID, guildID)
+	if s == nil {
+		return nil, false
+	}
+	s.RLock()
+	defer s.RUnlock()
+	conn, ok := s.VoiceConnections[guildID]
 	return conn, ok
 }
 
-// Play plays tts sound on VC
-func Play(text, lang, voiceToken, guildID string) error {
-	conn, ok := voiceConnection(guildID)
+// InVC reports whether the bot has a voice connection on the guild, including one being reconnected by discordgo
+func InVC(botID, guildID string) bool {
+	_, ok := voiceConnection(botID, guildID)
+	return ok
+}
+
+// MoveVC moves the bot to another voice channel of the guild
+func MoveVC(botID, guildID, vcID string) error {
+	conn, ok := voiceConnection(botID, guildID)
+	if !ok {
+		return fmt.Errorf("bot %s is not joining any voice channel on guild %s", botID, guildID)
+	}
+	return conn.ChangeChannel(vcID, false, true)
+}
+
+// readyPollInterval is interval to check whether the voice connection is ready again
+const readyPollInterval = 100 * time.Millisecond
+
+// waitReady waits until the voice connection gets ready, e.g. reconnected after the voice server is changed
+func waitReady(ctx context.Context, conn *discordgo.VoiceConnection) error {
+	for {
+		conn.RLock()
+		ready := conn.Ready
+		conn.RUnlock()
+		if ready {
+			return nil
+		}
+		select {
+		case <-time.After(readyPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Play plays tts sound on VC. rate is multiplier of speaking rate of the voice, 0 means 1.
+// it stops between Opus packets when ctx is done, and waits while the consumer running it is paused.
+func Play(ctx context.Context, text, lang, voiceToken string, rate float64, botID, guildID string) error {
+	conn, ok := voiceConnection(botID, guildID)
 	if !ok {
 		return fmt.Errorf("voice channel on guild %s is deleted. maybe zombie worker", guildID)
 	}
 
-	oggBuf, err := tts.OGGGoogle(text, lang, voiceToken)
+	oggBuf, err := tts.OGGGoogle(ctx, text, lang, voiceToken, rate)
 	if err != nil {
 		log.Printf("failed to create tts audio: %s", err.Error())
 		return nil
 	}
 	if err := conn.Speaking(true); err != nil {
 	}
+	defer func() {
+		if err := conn.Speaking(false); err != nil {
+		}
+	}()
 	for _, buff := range oggBuf {
-		conn.OpusSend <- buff
-	}
-	if err := conn.Speaking(false); err != nil {
-	}
-	return nil
-}
-
-// Alone returns whether the bot is alone on VC
-func Alone(guildID string) bool {
-	conn, ok := voiceConnection(guildID)
-	if !ok {
-		return true
-	}
-	vcID := ""
-	g, err := dg.State.Guild(conn.GuildID)
-	if err != nil {
-		log.Print("error failed to get state of guild by GuildID : ", err.Error())
-		return true
-	}
-	for _, vs := range g.VoiceStates {
-		if vs.UserID == conn.UserID {
-			vcID = vs.ChannelID
-			break
+		if err := worker.WaitResumed(ctx); err != nil {
+			return err
 		}
-	}
-	if vcID == "" {
-		log.Print("error voice channel bot is joining not found")
-		return true
-	}
-	count := 0
-	for _, vs := range g.VoiceStates {
-		if vs.ChannelID == vcID {
-			count++
+		if err := waitReady(ctx, conn); err != nil {
+			return err
+		}
+		select {
+		case conn.OpusSend <- buff:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
-	return count <= 1
+	return nil
 }
@@ -0,0 +1,51 @@
+package discord
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestWaitReady(t *testing.T) {
+	conn := &discordgo.VoiceConnection{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*readyPollInterval)
+	defer cancel()
+	if err := waitReady(ctx, conn); err != context.DeadlineExceeded {
+		t.Errorf("waitReady() on not ready connection = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// gets ready while waiting, e.g. reconnected after moved
+	go func() {
+		time.Sleep(2 * readyPollInterval)
+		conn.Lock()
+		conn.Ready = true
+		conn.Unlock()
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*readyPollInterval)
+	defer cancel()
+	if err := waitReady(ctx, conn); err != nil {
+		t.Errorf("waitReady() = %v, want nil", err)
+	}
+}
+
+func TestSplitTokens(t *testing.T) {
+	tests := []struct {
+		tokens, token string
+		want          []string
+	}{
+		{"", "", []string{}},
+		{"", "t", []string{"t"}},
+		{"a,b", "", []string{"a", "b"}},
+		{" a , b ,", "t", []string{"a", "b"}},
+		{",,", "t", []string{"t"}},
+	}
+	for _, tt := range tests {
+		if got := splitTokens(tt.tokens, tt.token); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitTokens(%q, %q) = %q, want %q", tt.tokens, tt.token, got, tt.want)
+		}
+	}
+}
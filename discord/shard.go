@@ -0,0 +1,74 @@
+package discord
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	shardCountEnv string = os.Getenv("SHARD_COUNT") // total number of shards. empty or 0 uses the number recommended by Discord
+	shardIDsEnv   string = os.Getenv("SHARD_IDS")   // comma separated IDs of shards run by this process. empty runs all
+)
+
+// shardConfig returns the number of shards and IDs of shards of the bot to run from environment variables
+func shardConfig(token string) (count int, ids []int, err error) {
+	if shardCountEnv != "" {
+		if count, err = strconv.Atoi(shardCountEnv); err != nil || count < 0 {
+			return 0, nil, fmt.Errorf("SHARD_COUNT must be a non-negative integer: %q", shardCountEnv)
+		}
+	}
+	if count == 0 {
+		if count, err = recommendedShardCount(token); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if shardIDsEnv == "" {
+		for id := 0; id < count; id++ {
+			ids = append(ids, id)
+		}
+		return count, ids, nil
+	}
+	// duplicated IDs are run once
+	seen := map[int]bool{}
+	for _, f := range strings.Split(shardIDsEnv, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || id < 0 || id >= count {
+			return 0, nil, fmt.Errorf("SHARD_IDS must be comma separated integers in [0, %d): %q", count, shardIDsEnv)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return count, ids, nil
+}
+
+// recommendedShardCount returns the number of shards of the bot recommended by Discord
+func recommendedShardCount(token string) (int, error) {
+	s, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return 0, fmt.Errorf("error creating Discord session: %w", err)
+	}
+	g, err := s.GatewayBot()
+	if err != nil {
+		return 0, fmt.Errorf("error get recommended number of shards: %w", err)
+	}
+	if g.Shards < 1 {
+		return 1, nil
+	}
+	return g.Shards, nil
+}
+
+// shardOf returns ID of the shard which receives events of the guild out of count shards
+func shardOf(guildID string, count int) int {
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil || count == 0 {
+		return 0
+	}
+	return int((id >> 22) % uint64(count))
+}
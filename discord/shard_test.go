@@ -0,0 +1,62 @@
+package discord
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShardConfig(t *testing.T) {
+	defer func(count, ids string) { shardCountEnv, shardIDsEnv = count, ids }(shardCountEnv, shardIDsEnv)
+
+	// SHARD_COUNT is always given not to get the recommended number from Discord
+	tests := []struct {
+		count, ids string
+		wantCount  int
+		wantIDs    []int
+		wantErr    bool
+	}{
+		{"1", "", 1, []int{0}, false},
+		{"3", "", 3, []int{0, 1, 2}, false},
+		{"4", "1,3", 4, []int{1, 3}, false},
+		{"4", " 2 , 0 ", 4, []int{2, 0}, false},
+		{"4", "1,1,3,1", 4, []int{1, 3}, false},
+		{"-1", "", 0, nil, true},
+		{"x", "", 0, nil, true},
+		{"4", "4", 0, nil, true},
+		{"4", "-1", 0, nil, true},
+		{"4", "1,,2", 0, nil, true},
+		{"4", "a", 0, nil, true},
+	}
+	for _, tt := range tests {
+		shardCountEnv, shardIDsEnv = tt.count, tt.ids
+		count, ids, err := shardConfig("token")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("shardConfig() with SHARD_COUNT=%q SHARD_IDS=%q error = %v, wantErr %v", tt.count, tt.ids, err, tt.wantErr)
+			continue
+		}
+		if count != tt.wantCount || !reflect.DeepEqual(ids, tt.wantIDs) {
+			t.Errorf("shardConfig() with SHARD_COUNT=%q SHARD_IDS=%q = %d, %v, want %d, %v", tt.count, tt.ids, count, ids, tt.wantCount, tt.wantIDs)
+		}
+	}
+}
+
+func TestShardOf(t *testing.T) {
+	tests := []struct {
+		guildID string
+		count   int
+		want    int
+	}{
+		{"0", 1, 0},
+		{"41771983423143937", 1, 0},
+		{"41771983423143937", 5, 4},  // 41771983423143937 >> 22 = 9959216934
+		{"41771983423143937", 16, 6}, // 9959216934 % 16 = 6
+		{"4194304", 2, 1},            // 1 << 22
+		{"not a number", 4, 0},
+		{"41771983423143937", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := shardOf(tt.guildID, tt.count); got != tt.want {
+			t.Errorf("shardOf(%q, %d) = %d, want %d", tt.guildID, tt.count, got, tt.want)
+		}
+	}
+}
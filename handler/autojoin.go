@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/db"
+	"github.com/tubo28/yomiage/i18n"
+)
+
+// autoJoin makes a free bot start a session if a member joined a voice channel with an auto join rule as the first member
+func autoJoin(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	if v.ChannelID == "" || v.BeforeUpdate != nil && v.BeforeUpdate.ChannelID == v.ChannelID {
+		return
+	}
+	if isBot(s, v.GuildID, v.UserID) {
+		return
+	}
+
+	tcID, err := db.GetAutoJoin(v.ChannelID)
+	if err != nil {
+		log.Print("error get auto join rule of voice channel ", v.ChannelID, ": ", err)
+		return
+	}
+	if tcID == "" {
+		return
+	}
+	if len(humans(s, v.GuildID, v.ChannelID)) != 1 {
+		return
+	}
+	for _, c := range guildBindings(v.GuildID) {
+		if c.voiceChannel() == v.ChannelID {
+			return
+		}
+	}
+	fs, ok := freeBot(v.GuildID)
+	if !ok {
+		log.Printf("all bots are working on guild %s, don't join voice channel %s automatically", v.GuildID, v.ChannelID)
+		return
+	}
+
+	log.Printf("member %s joined voice channel %s on guild %s, bot %s joins automatically", v.UserID, v.ChannelID, v.GuildID, fs.State.User.ID)
+	msg, ok := startSession(fs, v.GuildID, v.ChannelID, tcID, uiLang(s, v.GuildID))
+	if !ok || msg == "" {
+		return
+	}
+	if _, err := fs.ChannelMessageSend(tcID, msg); err != nil {
+		log.Print("error send message to channel ", tcID, " on guild ", v.GuildID, ": ", err)
+	}
+}
+
+// actions of autojoin command
+const (
+	autoJoinList   = "list"
+	autoJoinAdd    = "add"
+	autoJoinRemove = "remove"
+)
+
+func autoJoinActions() []string {
+	return []string{autoJoinList, autoJoinAdd, autoJoinRemove}
+}
+
+// isVoiceChannel reports whether the channel is a voice or stage channel
+func isVoiceChannel(s *discordgo.Session, channelID string) bool {
+	ch, err := s.State.Channel(channelID)
+	if err != nil {
+		log.Print("error find channel ", channelID, ": ", err)
+		return false
+	}
+	return ch.Type == discordgo.ChannelTypeGuildVoice || ch.Type == discordgo.ChannelTypeGuildStageVoice
+}
+
+func autoJoinHandler(r *request, args []string) {
+	action := autoJoinList
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case autoJoinList:
+		rules, err := db.ListAutoJoins(r.guildID)
+		if err != nil {
+			log.Print("error get auto join rules of guild ", r.guildID, ": ", err)
+			return
+		}
+		if len(rules) == 0 {
+			r.reply(r.t(i18n.AutoJoinEmpty))
+			return
+		}
+		lines := []string{}
+		for _, a := range rules {
+			lines = append(lines, r.t(i18n.AutoJoinRule, "voice", "<#"+a.VoiceChannelID+">", "text", "<#"+a.TextChannelID+">"))
+		}
+		r.reply(strings.Join(lines, "\n"))
+		return
+	case autoJoinAdd, autoJoinRemove:
+	default:
+		r.reply(r.t(i18n.AutoJoinUnknownAction, "action", action, "actions", strings.Join(autoJoinActions(), ", ")))
+		return
+	}
+
+	if len(args) < 2 {
+		r.reply(r.t(i18n.AutoJoinNoVoiceChannel))
+		return
+	}
+	vcID, ok := parseChannel(r.s, r.guildID, args[1])
+	if !ok || !isVoiceChannel(r.s, vcID) {
+		r.reply(r.t(i18n.AutoJoinNoVoiceChannel))
+		return
+	}
+	vc := "<#" + vcID + ">"
+
+	if action == autoJoinRemove {
+		ok, err := db.DeleteAutoJoin(vcID)
+		if err != nil {
+			log.Print("error delete auto join rule of voice channel ", vcID, ": ", err)
+			return
+		}
+		if !ok {
+			r.reply(r.t(i18n.AutoJoinNotFound, "voice", vc))
+			return
+		}
+		r.reply(r.t(i18n.AutoJoinRemoved, "voice", vc))
+		return
+	}
+
+	// the channel the command is run on if not given
+	tcID := r.channelID
+	if len(args) > 2 {
+		if tcID, ok = parseChannel(r.s, r.guildID, strings.Join(args[2:], " ")); !ok {
+			r.reply(r.t(i18n.ChannelNotFound, "channel", strings.Join(args[2:], " ")))
+			return
+		}
+	}
+	if err := db.UpsertAutoJoin(r.guildID, vcID, tcID); err != nil {
+		log.Print("error update auto join rule of voice channel ", vcID, ": ", err)
+		return
+	}
+	r.reply(r.t(i18n.AutoJoinAdded, "voice", vc, "text", "<#"+tcID+">"))
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"log"
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/discord"
+)
+
+// bots run by this process. replaced in tests
+var (
+	botIDs     = discord.BotIDs
+	botSession = discord.Session
+)
+
+// sessionKey identifies a session of a bot on a guild. a bot can join only one VC per guild
+type sessionKey struct {
+	botID   string
+	guildID string
+}
+
+// binding returns the session of the bot on the guild
+func binding(botID, guildID string) (*ttsConsumerBinding, bool) {
+	ci, ok := consumers.Load(sessionKey{botID, guildID})
+	if !ok {
+		return nil, false
+	}
+	return ci.(*ttsConsumerBinding), true
+}
+
+// guildBindings returns sessions of all bots on the guild in the order of bots
+func guildBindings(guildID string) []*ttsConsumerBinding {
+	res := []*ttsConsumerBinding{}
+	for _, botID := range botIDs() {
+		if c, ok := binding(botID, guildID); ok {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// bindingOf returns the session which commands of the member should go to:
+// the one in VC of the member, or the one reading the channel
+func bindingOf(s *discordgo.Session, guildID, channelID, userID string) (*ttsConsumerBinding, bool) {
+	cs := guildBindings(guildID)
+	vs, err := discord.VoiceState(s, userID, guildID)
+	if err != nil {
+		log.Printf("failed to get VoiceState of guild %s: %s", guildID, err.Error())
+	}
+	if vs != nil {
+		for _, c := range cs {
+			if c.voiceChannel() == vs.ChannelID {
+				return c, true
+			}
+		}
+	}
+	for _, c := range cs {
+		if c.reads(s, channelID) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// target returns the session the command of r should go to.
+// it falls back to the session of the bot received the command
+func target(r *request) (*ttsConsumerBinding, bool) {
+	if c, ok := bindingOf(r.s, r.guildID, r.channelID, r.author.ID); ok {
+		return c, true
+	}
+	return binding(r.s.State.User.ID, r.guildID)
+}
+
+// primaryBot returns ID of the first bot on the guild, which handles events nobody else is responsible for
+func primaryBot(guildID string) string {
+	for _, botID := range botIDs() {
+		if onGuild(botID, guildID) {
+			return botID
+		}
+	}
+	return ""
+}
+
+// freeBot returns the session of the first bot on the guild which is not joining any VC of it
+func freeBot(guildID string) (*discordgo.Session, bool) {
+	for _, botID := range botIDs() {
+		if _, ok := binding(botID, guildID); ok || !onGuild(botID, guildID) {
+			continue
+		}
+		return botSession(botID, guildID), true
+	}
+	return nil, false
+}
+
+// onGuild reports whether the bot is a member of the guild and its shard is run by this process
+func onGuild(botID, guildID string) bool {
+	s := botSession(botID, guildID)
+	if s == nil {
+		return false
+	}
+	_, err := s.State.Guild(guildID)
+	return err == nil
+}
+
+// handles reports whether the bot of s handles the text command of the member.
+// commands with the prefix are received by all bots, so only the bot of the session they go to handles them
+func handles(s *discordgo.Session, m *discordgo.MessageCreate) bool {
+	if c, ok := bindingOf(s, m.GuildID, m.ChannelID, m.Author.ID); ok {
+		return c.botID == s.State.User.ID
+	}
+	return primaryBot(m.GuildID) == s.State.User.ID
+}
+
+// isPoolBot reports whether the user is one of the bots run by this process
+func isPoolBot(userID string) bool {
+	for _, botID := range botIDs() {
+		if botID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+var botMentionReg = regexp.MustCompile(`^\s*<@!?(\d+)>`)
+
+// mentionsBot reports whether content starts with mention to one of the bots, i.e. it is a command to the bot.
+// it is not read by other bots reading the channel, as commands by mention to a single bot were not read before
+func mentionsBot(content string, botIDs []string) bool {
+	m := botMentionReg.FindStringSubmatch(content)
+	if m == nil {
+		return false
+	}
+	for _, botID := range botIDs {
+		if m[1] == botID {
+			return true
+		}
+	}
+	return false
+}
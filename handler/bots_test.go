@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// withBots replaces bots run by this process with bots of ids on the guild.
+// all of them see member "u" in voice channel "vc2"
+func withBots(t *testing.T, guildID string, ids ...string) map[string]*discordgo.Session {
+	sessions := map[string]*discordgo.Session{}
+	for _, id := range ids {
+		state := discordgo.NewState()
+		state.User = &discordgo.User{ID: id, Bot: true}
+		err := state.GuildAdd(&discordgo.Guild{
+			ID:          guildID,
+			VoiceStates: []*discordgo.VoiceState{{GuildID: guildID, UserID: "u", ChannelID: "vc2"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions[id] = &discordgo.Session{State: state}
+	}
+
+	origIDs, origSession := botIDs, botSession
+	botIDs = func() []string { return ids }
+	botSession = func(botID, gID string) *discordgo.Session {
+		if s, ok := sessions[botID]; ok && gID == guildID {
+			return s
+		}
+		return nil
+	}
+	t.Cleanup(func() { botIDs, botSession = origIDs, origSession })
+	return sessions
+}
+
+// withBinding starts a fake session of the bot reading voice channel vcID and text channel tcID
+func withBinding(t *testing.T, botID, guildID, vcID, tcID string) *ttsConsumerBinding {
+	c := &ttsConsumerBinding{botID: botID, guildID: guildID, voiceChannelID: vcID, textChannels: map[string]bool{tcID: true}}
+	consumers.Store(sessionKey{botID, guildID}, c)
+	t.Cleanup(func() { consumers.Delete(sessionKey{botID, guildID}) })
+	return c
+}
+
+func TestPrimaryBot(t *testing.T) {
+	withBots(t, "g", "b1", "b2")
+	if got := primaryBot("g"); got != "b1" {
+		t.Errorf("primaryBot(g) = %q, want b1", got)
+	}
+	if got := primaryBot("other"); got != "" {
+		t.Errorf("primaryBot(other) = %q, want none", got)
+	}
+}
+
+func TestFreeBot(t *testing.T) {
+	sessions := withBots(t, "g", "b1", "b2")
+	if s, ok := freeBot("g"); !ok || s != sessions["b1"] {
+		t.Errorf("freeBot() = %v, %v, want b1", s, ok)
+	}
+	withBinding(t, "b1", "g", "vc1", "tc1")
+	if s, ok := freeBot("g"); !ok || s != sessions["b2"] {
+		t.Errorf("freeBot() with b1 reading = %v, %v, want b2", s, ok)
+	}
+	withBinding(t, "b2", "g", "vc2", "tc2")
+	if _, ok := freeBot("g"); ok {
+		t.Error("freeBot() with all bots reading is found")
+	}
+}
+
+func TestBindingOf(t *testing.T) {
+	sessions := withBots(t, "g", "b1", "b2")
+	withBinding(t, "b1", "g", "vc1", "tc1")
+	withBinding(t, "b2", "g", "vc2", "tc2")
+
+	tests := []struct {
+		name      string
+		userID    string
+		channelID string
+		want      string // bot of the session. empty if not found
+	}{
+		{"member in VC of a bot", "u", "tc1", "b2"},
+		{"member not in VC on TC read", "x", "tc1", "b1"},
+		{"member not in VC on other TC read", "x", "tc2", "b2"},
+		{"member not in VC on TC not read", "x", "tc3", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := bindingOf(sessions["b1"], "g", tt.channelID, tt.userID)
+			got := ""
+			if ok {
+				got = c.botID
+			}
+			if got != tt.want {
+				t.Errorf("bindingOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandles(t *testing.T) {
+	sessions := withBots(t, "g", "b1", "b2")
+	withBinding(t, "b2", "g", "vc2", "tc2")
+
+	tests := []struct {
+		name      string
+		userID    string
+		channelID string
+		want      string // bot handling the command
+	}{
+		{"member in VC of a bot", "u", "tc1", "b2"},
+		{"on TC read", "x", "tc2", "b2"},
+		{"nobody responsible", "x", "tc1", "b1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &discordgo.MessageCreate{Message: &discordgo.Message{GuildID: "g", ChannelID: tt.channelID, Author: &discordgo.User{ID: tt.userID}}}
+			for _, id := range []string{"b1", "b2"} {
+				if got := handles(sessions[id], m); got != (id == tt.want) {
+					t.Errorf("handles() by %s = %v, want %v", id, got, id == tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMentionsBot(t *testing.T) {
+	bots := []string{"111", "222"}
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"<@111> bye", true},
+		{" <@!222> skip", true},
+		{"<@222>", true},
+		{"<@333> hello", false},
+		{"hello <@111>", false},
+		{"<@&111> role mention", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := mentionsBot(tt.content, bots); got != tt.want {
+			t.Errorf("mentionsBot(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestIsPoolBot(t *testing.T) {
+	withBots(t, "g", "b1", "b2")
+	tests := []struct {
+		userID string
+		want   bool
+	}{
+		{"b1", true},
+		{"b2", true},
+		{"u", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isPoolBot(tt.userID); got != tt.want {
+			t.Errorf("isPoolBot(%q) = %v, want %v", tt.userID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
+	"github.com/tubo28/yomiage/db"
+	"github.com/tubo28/yomiage/i18n"
+)
+
+// reads reports whether the bot reads messages on the channel.
+// threads are read if their parent channel is read.
+// it is called for every message, so channels are looked up only in the state, where active threads are cached
+func (c *ttsConsumerBinding) reads(s *discordgo.Session, channelID string) bool {
+	c.mu.Lock()
+	ok := c.textChannels[channelID]
+	c.mu.Unlock()
+	if ok {
+		return true
+	}
+
+	ch, err := s.State.Channel(channelID)
+	if err != nil {
+		return false
+	}
+	if !ch.IsThread() {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.textChannels[ch.ParentID]
+}
+
+// addTextChannel starts reading the channel
+func (c *ttsConsumerBinding) addTextChannel(channelID string) {
+	c.mu.Lock()
+	c.textChannels[channelID] = true
+	c.mu.Unlock()
+	c.save()
+}
+
+// removeTextChannel stops reading the channel. false is returned if it is not read
+func (c *ttsConsumerBinding) removeTextChannel(channelID string) bool {
+	c.mu.Lock()
+	if !c.textChannels[channelID] {
+		c.mu.Unlock()
+		return false
+	}
+	delete(c.textChannels, channelID)
+	c.mu.Unlock()
+	c.save()
+	return true
+}
+
+// textChannelIDs returns IDs of channels read in ascending order
+func (c *ttsConsumerBinding) textChannelIDs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := []string{}
+	for id := range c.textChannels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// textChannelNames returns mentions of channels read joined by comma
+func (c *ttsConsumerBinding) textChannelNames() string {
+	names := []string{}
+	for _, id := range c.textChannelIDs() {
+		names = append(names, "<#"+id+">")
+	}
+	return strings.Join(names, ", ")
+}
+
+// channelOverride returns language and voice token set to the channel or its parent if it is a thread.
+// empty strings are returned if not set. it is called for every message read, so the channel is looked up only in the state
+func channelOverride(s *discordgo.Session, channelID string) (lang, voiceToken string) {
+	ids := []string{channelID}
+	if ch, err := s.State.Channel(channelID); err == nil && ch.IsThread() {
+		ids = append(ids, ch.ParentID)
+	}
+	for _, id := range ids {
+		if lang == "" {
+			l, err := db.GetChannelLanguage(id)
+			if err != nil {
+				log.Print("error get channel ", id, "'s language: ", err)
+			}
+			lang = l
+		}
+		if voiceToken == "" {
+			vt, err := db.GetChannelVoiceToken(id)
+			if err != nil {
+				log.Print("error get channel ", id, "'s voice token: ", err)
+			}
+			voiceToken = vt
+		}
+	}
+	return lang, voiceToken
+}
+
+// actions of channel command
+const (
+	channelList   = "list"
+	channelAdd    = "add"
+	channelRemove = "remove"
+	channelLang   = "lang"
+	channelVoice  = "voice"
+)
+
+func channelActions() []string {
+	return []string{channelList, channelAdd, channelRemove, channelLang, channelVoice}
+}
+
+var channelMentionReg = regexp.MustCompile(`^<#(\d+)>$`)
+
+// parseChannel returns ID of the channel of the guild given as a mention, an ID or a name
+func parseChannel(s *discordgo.Session, guildID, val string) (string, bool) {
+	if m := channelMentionReg.FindStringSubmatch(val); m != nil {
+		val = m[1]
+	}
+	g, err := s.State.Guild(guildID)
+	if err != nil {
+		log.Print("error find guild ", guildID, ": ", err)
+		return "", false
+	}
+	for _, chs := range [][]*discordgo.Channel{g.Channels, g.Threads} {
+		for _, ch := range chs {
+			if ch.ID == val || ch.Name == strings.TrimPrefix(val, "#") {
+				return ch.ID, true
+			}
+		}
+	}
+	return "", false
+}
+
+func channelHandler(r *request, args []string) {
+	action := channelList
+	if len(args) > 0 {
+		action = args[0]
+	}
+	val := ""
+	if len(args) > 1 {
+		val = strings.Join(args[1:], " ")
+	}
+
+	switch action {
+	case channelList, channelAdd, channelRemove, channelLang, channelVoice:
+	default:
+		r.reply(r.t(i18n.ChannelUnknownAction, "action", action, "actions", strings.Join(channelActions(), ", ")))
+		return
+	}
+
+	// actions except list change settings of the guild
+	if action != channelList && !r.allowed(discordgo.PermissionManageServer) {
+		r.reply(deniedMessage(r, discordgo.PermissionManageServer))
+		return
+	}
+
+	switch action {
+	case channelLang:
+		channelLangHandler(r, val)
+		return
+	case channelVoice:
+		channelVoiceHandler(r, val)
+		return
+	}
+
+	c, ok := target(r)
+	if !ok {
+		r.reply(r.t(i18n.NotReading))
+		return
+	}
+
+	if action == channelList {
+		r.reply(r.t(i18n.ChannelList, "channels", c.textChannelNames()))
+		return
+	}
+
+	// the channel the command is run on if not given
+	channelID := r.channelID
+	if val != "" {
+		if channelID, ok = parseChannel(r.s, r.guildID, val); !ok {
+			r.reply(r.t(i18n.ChannelNotFound, "channel", val))
+			return
+		}
+	}
+
+	if action == channelAdd {
+		c.addTextChannel(channelID)
+		r.reply(r.t(i18n.ChannelAdded, "channel", "<#"+channelID+">"))
+		return
+	}
+	if !c.removeTextChannel(channelID) {
+		r.reply(r.t(i18n.ChannelNotRead, "channel", "<#"+channelID+">"))
+		return
+	}
+	r.reply(r.t(i18n.ChannelRemoved, "channel", "<#"+channelID+">"))
+}
+
+// channelLangHandler shows or sets the language of the channel the command is run on
+func channelLangHandler(r *request, lang string) {
+	ch := "<#" + r.channelID + ">"
+	switch lang {
+	case "":
+		l, _ := channelOverride(r.s, r.channelID)
+		if l == "" {
+			r.reply(r.t(i18n.ChannelLangNotSet, "channel", ch))
+			return
+		}
+		r.reply(r.t(i18n.ChannelLangGet, "channel", ch, "lang", l))
+		return
+	case "default":
+		lang = ""
+	}
+
+	if err := db.UpsertChannelLanguage(r.channelID, lang); err != nil {
+		log.Print("error update channel ", r.channelID, "'s language to ", lang, ": ", err)
+		return
+	}
+	if lang == "" {
+		r.reply(r.t(i18n.ChannelLangNotSet, "channel", ch))
+		return
+	}
+	r.reply(r.t(i18n.ChannelLangSet, "channel", ch, "lang", lang))
+}
+
+// channelVoiceHandler randomizes the voice of the channel the command is run on, or resets it by "default"
+func channelVoiceHandler(r *request, val string) {
+	ch := "<#" + r.channelID + ">"
+	vt := ""
+	if val != "default" {
+		u, _ := uuid.NewUUID()
+		vt = u.String()
+	}
+	if err := db.UpsertChannelVoiceToken(r.channelID, vt); err != nil {
+		log.Print("error update channel ", r.channelID, "'s voice token: ", err)
+		return
+	}
+	if vt == "" {
+		r.reply(r.t(i18n.ChannelVoiceReset, "channel", ch))
+		return
+	}
+	r.reply(r.t(i18n.ChannelVoiceSet, "channel", ch))
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestReads(t *testing.T) {
+	state := discordgo.NewState()
+	err := state.GuildAdd(&discordgo.Guild{
+		ID: "g",
+		Channels: []*discordgo.Channel{
+			{ID: "read", GuildID: "g", Type: discordgo.ChannelTypeGuildText},
+			{ID: "other", GuildID: "g", Type: discordgo.ChannelTypeGuildText},
+		},
+		Threads: []*discordgo.Channel{
+			{ID: "thread", GuildID: "g", ParentID: "read", Type: discordgo.ChannelTypeGuildPublicThread},
+			{ID: "other_thread", GuildID: "g", ParentID: "other", Type: discordgo.ChannelTypeGuildPublicThread},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &discordgo.Session{State: state}
+	c := &ttsConsumerBinding{textChannels: map[string]bool{"read": true}}
+
+	tests := []struct {
+		channelID string
+		want      bool
+	}{
+		{"read", true},
+		{"other", false},
+		{"thread", true},
+		{"other_thread", false},
+		{"unknown", false}, // not looked up by the API
+	}
+	for _, tt := range tests {
+		if got := c.reads(s, tt.channelID); got != tt.want {
+			t.Errorf("reads(%q) = %v, want %v", tt.channelID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/db"
+	"github.com/tubo28/yomiage/i18n"
+)
+
+// commandArg is an argument of a command
+type commandArg struct {
+	name        string
+	description i18n.Key
+	choices     func() []string // valid values if not nil
+	complete    func() []string // candidates for autocomplete if not nil
+}
+
+// command is a bot command available as both a text command and a slash command
+type command struct {
+	name       string
+	aliases    []string // other names available as text commands
+	args       []commandArg
+	permission int64 // discord permission bits required to run unless the member has admin roles. 0 if anyone can run
+	help       i18n.Key
+	ephemeral  bool // whether the response to the slash command is shown only to the author
+	handle     func(r *request, args []string)
+}
+
+// commands are all commands in the order shown by help
+var commands []*command
+
+// commandIndex maps names and aliases to commands
+var commandIndex = map[string]*command{}
+
+func init() {
+	// initialized here because help refers to commands
+	commands = []*command{
+		{
+			name:   "hi",
+			help:   i18n.CmdHi,
+			handle: func(r *request, args []string) { hiHandler(r) },
+		},
+		{
+			name:   "bye",
+			help:   i18n.CmdBye,
+			handle: func(r *request, args []string) { byeHandler(r) },
+		},
+		{
+			name:      "help",
+			help:      i18n.CmdHelp,
+			ephemeral: true,
+			handle:    func(r *request, args []string) { helpHandler(r) },
+		},
+		{
+			name:      "lang",
+			args:      []commandArg{{name: "code", description: i18n.CmdLangCode, complete: languageCodes}},
+			help:      i18n.CmdLang,
+			ephemeral: true,
+			handle:    langHandler,
+		},
+		{
+			name:    "voice",
+			aliases: []string{"rand"},
+			help:    i18n.CmdVoice,
+			handle:  randHandler,
+		},
+		{
+			name: "config",
+			args: []commandArg{
+				{name: "key", description: i18n.CmdConfigKey, choices: guildSettingNames},
+				{name: "value", description: i18n.CmdConfigValue},
+			},
+			permission: discordgo.PermissionManageServer,
+			help:       i18n.CmdConfig,
+			ephemeral:  true,
+			handle:     configHandler,
+		},
+		{
+			name: "channel",
+			args: []commandArg{
+				{name: "action", description: i18n.CmdChannelAction, choices: channelActions},
+				{name: "value", description: i18n.CmdChannelValue},
+			},
+			help:   i18n.CmdChannel,
+			handle: channelHandler,
+		},
+		{
+			name: "autojoin",
+			args: []commandArg{
+				{name: "action", description: i18n.CmdAutoJoinAction, choices: autoJoinActions},
+				{name: "voice", description: i18n.CmdAutoJoinVoice},
+				{name: "text", description: i18n.CmdAutoJoinText},
+			},
+			permission: discordgo.PermissionManageServer,
+			help:       i18n.CmdAutoJoin,
+			handle:     autoJoinHandler,
+		},
+		{
+			name:   "skip",
+			help:   i18n.CmdSkip,
+			handle: func(r *request, args []string) { playbackHandler(r, skipHandler) },
+		},
+		{
+			name:   "stop",
+			help:   i18n.CmdStop,
+			handle: func(r *request, args []string) { playbackHandler(r, stopHandler) },
+		},
+		{
+			name:   "clear",
+			help:   i18n.CmdClear,
+			handle: func(r *request, args []string) { playbackHandler(r, clearHandler) },
+		},
+		{
+			name:   "pause",
+			help:   i18n.CmdPause,
+			handle: func(r *request, args []string) { playbackHandler(r, pauseHandler) },
+		},
+		{
+			name:   "resume",
+			help:   i18n.CmdResume,
+			handle: func(r *request, args []string) { playbackHandler(r, resumeHandler) },
+		},
+		{
+			name:      "queue",
+			help:      i18n.CmdQueue,
+			ephemeral: true,
+			handle:    func(r *request, args []string) { playbackHandler(r, queueHandler) },
+		},
+	}
+
+	for _, c := range commands {
+		commandIndex[c.name] = c
+		for _, a := range c.aliases {
+			commandIndex[a] = c
+		}
+	}
+}
+
+func guildSettingNames() []string {
+	names := []string{}
+	for _, gs := range db.GuildSettings {
+		names = append(names, string(gs))
+	}
+	return names
+}
+
+// run runs the command if the author of r has the permission
+func (c *command) run(r *request, args []string) {
+	if c.permission != 0 && !r.allowed(c.permission) {
+		r.reply(deniedMessage(r, c.permission))
+		return
+	}
+	c.handle(r, args)
+}
+
+// usage returns text like "!lang [code]"
+func (c *command) usage(prefix string) string {
+	u := prefix + c.name
+	for _, a := range c.args {
+		u += " [" + a.name + "]"
+	}
+	return u
+}
+
+func helpHandler(r *request) {
+	prefix := guildSetting(r.guildID, db.GuildPrefix)
+	lines := []string{}
+	for _, c := range commands {
+		line := fmt.Sprintf("`%s`", c.usage(prefix))
+		for _, a := range c.aliases {
+			line += fmt.Sprintf(", `%s%s`", prefix, a)
+		}
+		lines = append(lines, line+": "+r.t(c.help))
+	}
+	lines = append(lines, r.t(i18n.HelpForms))
+	lines = append(lines, r.t(i18n.HelpDetails, "url", "https://github.com/tubo28/yomiage/blob/main/README.md"))
+	r.reply(strings.Join(lines, "\n"))
+}
+
+// maxSuggestionDistance is the max edit distance of commands suggested for unknown commands
+const maxSuggestionDistance = 2
+
+// unknownCommandHandler tells name is not a command and suggests a similar one if any
+func unknownCommandHandler(r *request, name string) {
+	best, ok := suggestCommand(name)
+	if !ok {
+		r.reply(r.t(i18n.UnknownCommand, "command", name))
+		return
+	}
+	r.reply(r.t(i18n.DidYouMean, "command", name, "suggestion", best))
+}
+
+// suggestCommand returns the command or alias nearest to name within maxSuggestionDistance.
+// the first one in lexical order is returned among the nearest ones
+func suggestCommand(name string) (string, bool) {
+	best, bestDist := "", maxSuggestionDistance+1
+	for n := range commandIndex {
+		if d := levenshtein(name, n); d < bestDist || d == bestDist && n < best {
+			best, bestDist = n, d
+		}
+	}
+	return best, best != ""
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(br)]
+}
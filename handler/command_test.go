@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"help", "help", 0},
+		{"hlep", "help", 2},
+		{"hepl", "help", 2},
+		{"hel", "help", 1},
+		{"hellp", "help", 1},
+		{"kitten", "sitting", 3},
+		{"読み上げ", "読上げ", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"help", "help", true},
+		{"hlep", "help", true},
+		{"confg", "config", true},
+		{"autojion", "autojoin", true},
+		{"xxxxxxxx", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := suggestCommand(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("suggestCommand(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	s := &discordgo.Session{State: discordgo.NewState()}
+	s.State.User = &discordgo.User{ID: "1234"}
+	tests := []struct {
+		content  string
+		wantName string
+		wantArgs []string
+		wantOK   bool
+	}{
+		{"", "", nil, false},
+		{"hello", "", nil, false},
+		{"!", "", nil, false},
+		{"!  ", "", nil, false},
+		{"!hi", "hi", []string{}, true},
+		{"!config  prefix  ?", "config", []string{"prefix", "?"}, true},
+		{"<@1234>", "help", nil, true},
+		{" <@!1234> lang en", "lang", []string{"en"}, true},
+		{"<@5678> hi", "", nil, false},
+		{"hi <@1234>", "", nil, false},
+	}
+	for _, tt := range tests {
+		name, args, ok := parseCommand(s, "!", tt.content)
+		if name != tt.wantName || !reflect.DeepEqual(args, tt.wantArgs) || ok != tt.wantOK {
+			t.Errorf("parseCommand(%q) = %q, %q, %v, want %q, %q, %v", tt.content, name, args, ok, tt.wantName, tt.wantArgs, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/db"
+	"github.com/tubo28/yomiage/i18n"
+	"github.com/tubo28/yomiage/worker"
+)
+
+// values of db.GuildOverflow
+const (
+	overflowDropNewest = "drop_newest" // don't read the new message
+	overflowDropOldest = "drop_oldest" // don't read the oldest queued message
+	overflowNotify     = "notify"      // don't read the new message and tell it to the channel
+)
+
+// values of db.GuildSystemPriority
+var systemPriorities = map[string]int{
+	"normal":    worker.PriorityNormal,    // wait for queued messages
+	"high":      worker.PriorityHigh,      // read before queued messages
+	"interrupt": worker.PriorityInterrupt, // stop reading the current message and read it again after
+}
+
+// values of db.GuildSchedule
+const (
+	scheduleFIFO       = "fifo"        // in the order posted
+	scheduleRoundRobin = "round_robin" // one message of each member in turn
+)
+
+// guildSettingDefaults are used when the guild has not configured the setting.
+// defaults of templates are in the UI language of the guild, see announceTemplate
+var guildSettingDefaults = map[db.GuildSetting]string{
+	db.GuildEditBehavior:   editBehaviorReplace,
+	db.GuildQueueSize:      strconv.Itoa(worker.DefaultPolicy.Capacity),
+	db.GuildOverflow:       overflowDropNewest,
+	db.GuildMaxAge:         "0",
+	db.GuildSchedule:       scheduleRoundRobin,
+	db.GuildMaxPerAuthor:   "0",
+	db.GuildSystemPriority: "high",
+	db.GuildCoalesceMillis: "0",
+	db.GuildMaxRate:        "100",
+	db.GuildTextCommands:   "on",
+	db.GuildPrefix:         "!",
+	db.GuildIgnorePrefixes: "",
+	db.GuildIgnoreBots:     "off",
+	db.GuildUILang:         defaultUILang,
+	db.GuildAdminRoles:     "",
+	db.GuildIdleTimeout:    "10",
+	db.GuildFollowUser:     "",
+	db.GuildShutdownNotice: shutdownNoticePost,
+}
+
+// guildSettingChoices are valid values of settings which take one of fixed values
+var guildSettingChoices = map[db.GuildSetting][]string{
+	db.GuildEditBehavior:   {editBehaviorReplace, editBehaviorSkip},
+	db.GuildOverflow:       {overflowDropNewest, overflowDropOldest, overflowNotify},
+	db.GuildSchedule:       {scheduleFIFO, scheduleRoundRobin},
+	db.GuildSystemPriority: {"normal", "high", "interrupt"},
+	db.GuildTextCommands:   {"on", "off"},
+	db.GuildIgnoreBots:     {"on", "off"},
+	db.GuildUILang:         uiLangChoices(),
+	db.GuildShutdownNotice: {shutdownNoticeOff, shutdownNoticePost, shutdownNoticeSpeak},
+}
+
+// guildSettingRanges are valid ranges [min, max] of settings which take integer
+var guildSettingRanges = map[db.GuildSetting][2]int{
+	db.GuildQueueSize:      {1, 256},
+	db.GuildMaxAge:         {0, 3600},
+	db.GuildMaxPerAuthor:   {0, 256},
+	db.GuildCoalesceMillis: {0, 5000},
+	db.GuildMaxRate:        {100, 300},
+	db.GuildIdleTimeout:    {0, 3600},
+}
+
+// maxPrefixLength is the max length of db.GuildPrefix
+const maxPrefixLength = 8
+
+// validGuildSetting returns message in lang to show if val cannot be set to gs, or empty string if it can
+func validGuildSetting(lang i18n.Lang, gs db.GuildSetting, val string) string {
+	if val == "" {
+		return ""
+	}
+	if choices, ok := guildSettingChoices[gs]; ok {
+		for _, c := range choices {
+			if c == val {
+				return ""
+			}
+		}
+		return i18n.T(lang, i18n.ConfigChoices, "key", string(gs), "choices", strings.Join(choices, ", "))
+	}
+	if r, ok := guildSettingRanges[gs]; ok {
+		if n, err := strconv.Atoi(val); err == nil && r[0] <= n && n <= r[1] {
+			return ""
+		}
+		return i18n.T(lang, i18n.ConfigRange, "key", string(gs), "min", strconv.Itoa(r[0]), "max", strconv.Itoa(r[1]))
+	}
+	if gs == db.GuildPrefix && (len([]rune(val)) > maxPrefixLength || strings.ContainsAny(val, " \t\n")) {
+		return i18n.T(lang, i18n.ConfigPrefix, "key", string(gs), "max", strconv.Itoa(maxPrefixLength))
+	}
+	return ""
+}
+
+// guildSetting returns the value of setting gs on the guild, or its default if not configured
+func guildSetting(guildID string, gs db.GuildSetting) string {
+	v, err := db.GetGuildSetting(guildID, gs)
+	if err != nil {
+		log.Print("error get guild ", guildID, "'s setting ", gs, ": ", err)
+	}
+	if v == "" {
+		return guildSettingDefaults[gs]
+	}
+	return v
+}
+
+// guildSettingInt returns the value of integer setting gs on the guild
+func guildSettingInt(guildID string, gs db.GuildSetting) int {
+	v := guildSetting(guildID, gs)
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Print("error parse guild ", guildID, "'s setting ", gs, " = ", v, ": ", err)
+		n, _ = strconv.Atoi(guildSettingDefaults[gs])
+	}
+	return n
+}
+
+// guildPolicy returns the queue policy of the guild
+func guildPolicy(guildID string) worker.Policy {
+	p := worker.DefaultPolicy
+	p.Capacity = guildSettingInt(guildID, db.GuildQueueSize)
+	if guildSetting(guildID, db.GuildOverflow) == overflowDropOldest {
+		p.Overflow = worker.DropOldest
+	}
+	p.MaxAge = time.Duration(guildSettingInt(guildID, db.GuildMaxAge)) * time.Second
+	if guildSetting(guildID, db.GuildSchedule) == scheduleFIFO {
+		p.Schedule = worker.FIFO
+	}
+	p.MaxPerAuthor = guildSettingInt(guildID, db.GuildMaxPerAuthor)
+	p.CoalesceWindow = time.Duration(guildSettingInt(guildID, db.GuildCoalesceMillis)) * time.Millisecond
+	p.MaxRate = float64(guildSettingInt(guildID, db.GuildMaxRate)) / 100
+	return p
+}
+
+// uiLangAuto is the value of db.GuildUILang to follow the preferred locale of the guild
+const uiLangAuto = "auto"
+
+// defaultUILang is the default of db.GuildUILang
+var defaultUILang = os.Getenv("DEFAULT_UI_LANG")
+
+func init() {
+	if defaultUILang == "" {
+		defaultUILang = uiLangAuto
+	}
+}
+
+func uiLangChoices() []string {
+	choices := []string{uiLangAuto}
+	for _, l := range i18n.Langs {
+		choices = append(choices, string(l))
+	}
+	return choices
+}
+
+// uiLang returns the language of messages on the guild.
+// English is used if the setting is auto and the preferred locale of the guild has no catalog
+func uiLang(s *discordgo.Session, guildID string) i18n.Lang {
+	v := guildSetting(guildID, db.GuildUILang)
+	if l, ok := i18n.Parse(v); ok {
+		return l
+	}
+	if g, err := s.State.Guild(guildID); err == nil {
+		if l, ok := i18n.FromLocale(g.PreferredLocale); ok {
+			return l
+		}
+	}
+	return i18n.En
+}
+
+// systemTTSLangs are languages to read text of the bot such as announcements in each UI language
+var systemTTSLangs = map[i18n.Lang]string{
+	i18n.Ja: "ja-JP",
+	i18n.En: "en-US",
+}
+
+// systemTTSLang returns language to read text of the bot written in the UI language lang
+func systemTTSLang(lang i18n.Lang) string {
+	if l, ok := systemTTSLangs[lang]; ok {
+		return l
+	}
+	return defaultTTSLang
+}
+
+// systemPriority returns priority of speech by the bot such as announcements on the guild
+func systemPriority(guildID string) int {
+	return systemPriorities[guildSetting(guildID, db.GuildSystemPriority)]
+}
+
+// shownGuildSetting returns the value of setting gs on the guild of r including the default one actually used
+func shownGuildSetting(r *request, gs db.GuildSetting) string {
+	if _, ok := announceTemplates[gs]; ok {
+		return announceTemplate(r.lang(), r.guildID, gs)
+	}
+	return guildSetting(r.guildID, gs)
+}
+
+func configHandler(r *request, args []string) {
+	var msg string
+	switch {
+	case len(args) == 0:
+		// list all settings
+		lines := []string{}
+		for _, gs := range db.GuildSettings {
+			lines = append(lines, fmt.Sprintf("%s: %s", gs, shownGuildSetting(r, gs)))
+		}
+		msg = strings.Join(lines, "\n")
+	default:
+		gs, ok := db.ParseGuildSetting(args[0])
+		if !ok {
+			msg = r.t(i18n.ConfigUnknown, "key", args[0])
+			break
+		}
+		if len(args) == 1 {
+			msg = fmt.Sprintf("%s: %s", gs, shownGuildSetting(r, gs))
+			break
+		}
+
+		val := strings.Join(args[1:], " ")
+		if val == "default" {
+			val = ""
+		}
+		if msg = validGuildSetting(r.lang(), gs, val); msg != "" {
+			break
+		}
+		if gs == db.GuildAdminRoles {
+			ids, ok := parseRoles(r.s, r.guildID, val)
+			if !ok {
+				msg = r.t(i18n.ConfigRoles, "key", string(gs))
+				break
+			}
+			val = ids
+		}
+		if gs == db.GuildFollowUser && val != "" {
+			id, ok := parseMember(r.s, r.guildID, val)
+			if !ok {
+				msg = r.t(i18n.ConfigMember, "key", string(gs))
+				break
+			}
+			val = id
+		}
+		if err := db.UpsertGuildSetting(r.guildID, gs, val); err != nil {
+			log.Print("error update guild ", r.guildID, "'s setting ", gs, ": ", err)
+			return
+		}
+		for _, c := range guildBindings(r.guildID) {
+			c.consumer.SetPolicy(guildPolicy(r.guildID))
+		}
+		msg = r.t(i18n.ConfigUpdated, "key", string(gs), "value", guildSetting(r.guildID, gs))
+	}
+
+	r.reply(msg)
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// cooldown limits events of the same key to once per d
+type cooldown struct {
+	d    time.Duration
+	last sync.Map // maps key to time.Time of the last allowed event
+
+	mu    sync.Mutex
+	swept time.Time // when expired keys are removed last
+}
+
+// allow reports whether an event of key can happen now, and records it if so
+func (c *cooldown) allow(key string) bool {
+	now := time.Now()
+	c.sweep(now)
+	if last, ok := c.last.Load(key); ok && now.Sub(last.(time.Time)) < c.d {
+		return false
+	}
+	c.last.Store(key, now)
+	return true
+}
+
+// sweep removes keys whose last event is older than d, at most once per d
+func (c *cooldown) sweep(now time.Time) {
+	c.mu.Lock()
+	if now.Sub(c.swept) < c.d {
+		c.mu.Unlock()
+		return
+	}
+	c.swept = now
+	c.mu.Unlock()
+
+	c.last.Range(func(key, last interface{}) bool {
+		if now.Sub(last.(time.Time)) >= c.d {
+			c.last.Delete(key)
+		}
+		return true
+	})
+}
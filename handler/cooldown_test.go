@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCooldown(t *testing.T) {
+	c := &cooldown{d: 50 * time.Millisecond}
+	if !c.allow("a") {
+		t.Error("first event of a should be allowed")
+	}
+	if c.allow("a") {
+		t.Error("second event of a in cooldown should not be allowed")
+	}
+	if !c.allow("b") {
+		t.Error("event of other key should be allowed")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if !c.allow("a") {
+		t.Error("event of a after cooldown should be allowed")
+	}
+	// b is expired and removed by the sweep
+	if _, ok := c.last.Load("b"); ok {
+		t.Error("expired key b should be removed")
+	}
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/db"
+	"github.com/tubo28/yomiage/discord"
+)
+
+// voiceChannel returns the voice channel the bot is reading in
+func (c *ttsConsumerBinding) voiceChannel() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.voiceChannelID
+}
+
+// rebind changes the voice channel of the session and counts members in it again
+func (c *ttsConsumerBinding) rebind(s *discordgo.Session, voiceChannelID string) {
+	members := map[string]bool{}
+	for _, id := range humans(s, c.guildID, voiceChannelID) {
+		members[id] = true
+	}
+
+	c.mu.Lock()
+	// the text chat of the voice channel moves with the bot
+	if c.textChannels[c.voiceChannelID] {
+		delete(c.textChannels, c.voiceChannelID)
+		c.textChannels[voiceChannelID] = true
+	}
+	c.voiceChannelID = voiceChannelID
+	c.members = members
+	c.mu.Unlock()
+	c.save()
+
+	if len(members) == 0 {
+		c.scheduleLeave(s)
+	} else {
+		c.cancelLeave()
+	}
+}
+
+// botVoiceStateUpdate follows changes of the bot's own voice state made by others
+func botVoiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	c, ok := binding(v.UserID, v.GuildID)
+	if !ok {
+		return
+	}
+
+	vcID := c.voiceChannel()
+	if v.ChannelID == "" {
+		// the voice connection is closed without bye, e.g. disconnected by a moderator or dropped.
+		// discordgo keeps reconnecting unless the bot leaves by itself, so queued messages are kept for a while
+		if !discord.InVC(c.botID, v.GuildID) {
+			log.Printf("bot %s is disconnected from voice channel %s on guild %s, stop reading", c.botID, vcID, v.GuildID)
+			_, _ = stopSession(c.botID, v.GuildID, uiLang(s, v.GuildID)) // message is not shown
+			return
+		}
+		log.Printf("bot %s is disconnected from voice channel %s on guild %s, wait for reconnection", c.botID, vcID, v.GuildID)
+		c.waitRejoin(s)
+		return
+	}
+
+	c.cancelRejoin()
+	if v.ChannelID != vcID {
+		log.Printf("bot %s is moved from voice channel %s to %s on guild %s", c.botID, vcID, v.ChannelID, v.GuildID)
+		c.rebind(s, v.ChannelID)
+	}
+}
+
+// rejoinTimeout is the max time to wait for the voice connection to be reconnected before stopping the session
+const rejoinTimeout = time.Minute
+
+// waitRejoin stops the session unless the bot gets back to a voice channel of the guild within rejoinTimeout
+func (c *ttsConsumerBinding) waitRejoin(s *discordgo.Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rejoinTimer != nil {
+		return
+	}
+	c.rejoinTimer = time.AfterFunc(rejoinTimeout, func() {
+		c.mu.Lock()
+		c.rejoinTimer = nil
+		c.mu.Unlock()
+
+		// the session may be replaced by a new one while waiting
+		if cur, ok := binding(c.botID, c.guildID); !ok || cur != c {
+			return
+		}
+		log.Printf("bot %s is not reconnected to voice channel on guild %s, stop reading", c.botID, c.guildID)
+		_, _ = stopSession(c.botID, c.guildID, uiLang(s, c.guildID)) // message is not shown
+	})
+}
+
+// cancelRejoin stops waiting for reconnection since the bot is back in a voice channel
+func (c *ttsConsumerBinding) cancelRejoin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rejoinTimer != nil {
+		c.rejoinTimer.Stop()
+		c.rejoinTimer = nil
+	}
+}
+
+// follows moves the bot to the voice channel the member to follow on the guild moved to from the bot's one.
+// true is returned if the bot is moved
+func follows(c *ttsConsumerBinding, v *discordgo.VoiceStateUpdate) bool {
+	if !shouldFollow(c, v) {
+		return false
+	}
+	log.Printf("member %s to follow moved to voice channel %s on guild %s", v.UserID, v.ChannelID, v.GuildID)
+	if err := discord.MoveVC(c.botID, v.GuildID, v.ChannelID); err != nil {
+		log.Print("error move to voice channel ", v.ChannelID, " on guild ", v.GuildID, ": ", err)
+		return false
+	}
+	return true
+}
+
+// shouldFollow reports whether v is a move of the member to follow from the voice channel of c to another one no bot is reading
+func shouldFollow(c *ttsConsumerBinding, v *discordgo.VoiceStateUpdate) bool {
+	vcID := c.voiceChannel()
+	if v.ChannelID == "" || v.ChannelID == vcID || v.BeforeUpdate == nil || v.BeforeUpdate.ChannelID != vcID {
+		return false
+	}
+	if guildSetting(v.GuildID, db.GuildFollowUser) != v.UserID {
+		return false
+	}
+	// another bot is already reading there
+	for _, other := range guildBindings(v.GuildID) {
+		if other.voiceChannel() == v.ChannelID {
+			return false
+		}
+	}
+	return true
+}
+
+var userMentionReg = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+// parseMember returns ID of the member of the guild given as a mention or an ID
+func parseMember(s *discordgo.Session, guildID, val string) (string, bool) {
+	if m := userMentionReg.FindStringSubmatch(val); m != nil {
+		val = m[1]
+	}
+	if _, err := s.State.Member(guildID, val); err == nil {
+		return val, true
+	}
+	if _, err := s.GuildMember(guildID, val); err != nil {
+		return "", false
+	}
+	return val, true
+}
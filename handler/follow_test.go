@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"os"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/tubo28/yomiage/db"
+)
+
+// initTestDB opens an empty db in a temporary directory for the test
+func initTestDB(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(dir+"/db-data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	// db is opened relative to the working directory
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	db.Init()
+	t.Cleanup(func() {
+		db.Close()
+		os.Chdir(wd)
+	})
+}
+
+func TestShouldFollow(t *testing.T) {
+	initTestDB(t)
+	if err := db.UpsertGuildSetting("g", db.GuildFollowUser, "u"); err != nil {
+		t.Fatal(err)
+	}
+	c := &ttsConsumerBinding{guildID: "g", voiceChannelID: "vc"}
+
+	tests := []struct {
+		name   string
+		userID string
+		before string // channel before the update. empty if not known
+		after  string
+		want   bool
+	}{
+		{"moved from the bot's channel", "u", "vc", "vc2", true},
+		{"other member", "other", "vc", "vc2", false},
+		{"left", "u", "vc", "", false},
+		{"joined", "u", "", "vc2", false},
+		{"moved between other channels", "u", "vc2", "vc3", false},
+		{"moved to the bot's channel", "u", "vc2", "vc", false},
+		{"muted in the bot's channel", "u", "vc", "vc", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &discordgo.VoiceStateUpdate{VoiceState: &discordgo.VoiceState{GuildID: "g", UserID: tt.userID, ChannelID: tt.after}}
+			if tt.before != "" {
+				v.BeforeUpdate = &discordgo.VoiceState{GuildID: "g", UserID: tt.userID, ChannelID: tt.before}
+			}
+			if got := shouldFollow(c, v); got != tt.want {
+				t.Errorf("shouldFollow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRebind(t *testing.T) {
+	initTestDB(t)
+	state := discordgo.NewState()
+	err := state.GuildAdd(&discordgo.Guild{
+		ID: "g",
+		Members: []*discordgo.Member{
+			{GuildID: "g", User: &discordgo.User{ID: "u1"}},
+			{GuildID: "g", User: &discordgo.User{ID: "u2"}},
+			{GuildID: "g", User: &discordgo.User{ID: "bot", Bot: true}},
+		},
+		VoiceStates: []*discordgo.VoiceState{
+			{GuildID: "g", UserID: "u1", ChannelID: "vc"},
+			{GuildID: "g", UserID: "u2", ChannelID: "vc2"},
+			{GuildID: "g", UserID: "bot", ChannelID: "vc2"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &discordgo.Session{State: state}
+	c := &ttsConsumerBinding{
+		guildID:        "g",
+		voiceChannelID: "vc",
+		textChannels:   map[string]bool{"tc": true, "vc": true},
+		members:        map[string]bool{"u1": true},
+	}
+	defer c.cancelLeave()
+
+	c.rebind(s, "vc2")
+	if got := c.voiceChannel(); got != "vc2" {
+		t.Errorf("voice channel = %s, want vc2", got)
+	}
+	if got := c.memberCount(); got != 1 || !c.members["u2"] {
+		t.Errorf("members = %v, want only u2", c.members)
+	}
+	if got, want := c.textChannelIDs(), []string{"tc", "vc2"}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("text channels = %v, want %v", got, want)
+	}
+	if c.leaveTimer != nil {
+		t.Error("leave is scheduled though a member is in the voice channel")
+	}
+
+	c.rebind(s, "vc3")
+	if c.memberCount() != 0 {
+		t.Errorf("members = %v, want none", c.members)
+	}
+	if c.leaveTimer == nil {
+		t.Error("leave is not scheduled though no members are in the voice channel")
+	}
+}
+
+func TestWaitRejoin(t *testing.T) {
+	c := &ttsConsumerBinding{botID: "b", guildID: "g"}
+	c.waitRejoin(nil)
+	timer := c.rejoinTimer
+	if timer == nil {
+		t.Fatal("rejoin is not waited for")
+	}
+	// waiting is not restarted by another disconnection
+	c.waitRejoin(nil)
+	if c.rejoinTimer != timer {
+		t.Error("waiting for rejoin is restarted")
+	}
+	// the bot is back in a voice channel
+	c.cancelRejoin()
+	if c.rejoinTimer != nil {
+		t.Error("waiting for rejoin is not canceled")
+	}
+	if timer.Stop() {
+		t.Error("timer to stop the session is still running")
+	}
+}
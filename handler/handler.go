@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/tubo28/yomiage/db"
 	"github.com/tubo28/yomiage/discord"
+	"github.com/tubo28/yomiage/i18n"
 	"github.com/tubo28/yomiage/worker"
 	"mvdan.cc/xurls"
 )
@@ -30,8 +32,26 @@ func init() {
 
 // Init adds handlers to discord
 func Init() {
+	// sessions saved by a single bot before are restored by the first one
+	if ids := discord.BotIDs(); len(ids) > 0 {
+		if err := db.ClaimSessions(ids[0]); err != nil {
+			log.Print("error claim saved sessions for bot ", ids[0], ": ", err)
+		}
+	}
+
 	discord.AddHandler(messageCreate)
-	go cleanerWorkerEndless()
+	discord.AddHandler(voiceStateUpdate)
+	discord.AddHandler(messageDelete)
+	discord.AddHandler(messageDeleteBulk)
+	discord.AddHandler(messageUpdate)
+	discord.AddHandler(interactionCreate)
+	discord.AddHandler(channelDelete)
+	discord.AddHandler(guildDelete)
+	discord.AddHandler(guildCreate)
+	// sessions are opened before handlers are added
+	restoreSessions()
+	discord.RegisterCommands(slashCommands())
+	go languageCodes() // fetch in advance because autocomplete must respond quickly
 }
 
 func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
@@ -41,124 +61,159 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		}
 	}()
 
-	if strings.HasPrefix(m.Content, "!hi") {
-		if m.Author.ID == s.State.User.ID {
-			return
-		}
-		hiHandler(s, m)
+	if isClosing() {
 		return
 	}
 
-	if strings.HasPrefix(m.Content, "!bye") {
-		if m.Author.ID == s.State.User.ID {
-			return
-		}
-		byeHandler(s, m)
+	// replies of the bots are never read nor run as commands
+	if isPoolBot(m.Author.ID) {
 		return
 	}
 
-	// if content starts with mention string to bot,
-	prefixPatStr := fmt.Sprintf(`^\s*<@!?%s>`, s.State.User.ID) // '<@1234> ...' or '<@!1234> ...'
-	prefixPat, err := regexp.Compile(prefixPatStr)
-	if err != nil {
-		log.Print("failed to compile mention prefix regexp: ", prefixPatStr)
+	if (m.Author.Bot || m.WebhookID != "") && guildSetting(m.GuildID, db.GuildIgnoreBots) == "on" {
+		log.Printf("message %s is posted by bot or webhook. message is ignored", m.ID)
+		return
 	}
-	if prefixPat != nil && prefixPat.MatchString(m.Content) {
-		noMentionContent := strings.TrimSpace(prefixPat.ReplaceAllString(m.Content, ""))
-		if noMentionContent == "" || noMentionContent == "help" {
-			if m.Author.ID == s.State.User.ID {
-				return
-			}
-			helpHandler(s, m)
+
+	prefix := guildSetting(m.GuildID, db.GuildPrefix)
+	if name, args, ok := parseCommand(s, prefix, m.Content); ok {
+		if m.Author.ID == s.State.User.ID {
 			return
 		}
-
-		args := strings.Fields(noMentionContent)
-		head, args := args[0], args[1:]
-		if head == "lang" {
-			if m.Author.ID == s.State.User.ID {
-				return
-			}
-			langHandler(s, m, args)
+		if guildSetting(m.GuildID, db.GuildTextCommands) == "off" {
+			log.Printf("text commands are disabled on guild %s. message is ignored", m.GuildID)
 			return
 		}
-		if head == "rand" {
-			if m.Author.ID == s.State.User.ID {
-				return
-			}
-			randHandler(s, m, args)
+		if strings.HasPrefix(m.Content, prefix) && !handles(s, m) {
 			return
 		}
+		r := newMessageRequest(s, m)
+		if c, ok := commandIndex[name]; ok {
+			c.run(r, args)
+		} else if !strings.HasPrefix(m.Content, prefix) {
+			// unknown commands with the prefix may be for other bots
+			unknownCommandHandler(r, name)
+		}
 		return
 	}
 
-	if !strings.HasPrefix(m.Content, "!") {
+	// commands to other bots are not read
+	if !ignoredPrefix(m.GuildID, prefix, m.Content) && !mentionsBot(m.Content, botIDs()) {
 		nonCommandHandler(s, m)
 	}
 }
 
-func langHandler(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
+// ignoredPrefix reports whether content starts with the command prefix or one of prefixes not read on the guild
+func ignoredPrefix(guildID, prefix, content string) bool {
+	prefixes := append(strings.Fields(guildSetting(guildID, db.GuildIgnorePrefixes)), prefix)
+	for _, p := range prefixes {
+		if strings.HasPrefix(content, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCommand splits content like "!name args..." or "<@bot> name args..." into the command name and args.
+// ok is false if content is not a command
+func parseCommand(s *discordgo.Session, prefix, content string) (name string, args []string, ok bool) {
+	if strings.HasPrefix(content, prefix) {
+		fs := strings.Fields(strings.TrimPrefix(content, prefix))
+		if len(fs) == 0 {
+			return "", nil, false
+		}
+		return fs[0], fs[1:], true
+	}
+
+	// if content starts with mention string to bot,
+	prefixPatStr := fmt.Sprintf(`^\s*<@!?%s>`, s.State.User.ID) // '<@1234> ...' or '<@!1234> ...'
+	prefixPat, err := regexp.Compile(prefixPatStr)
+	if err != nil {
+		log.Print("failed to compile mention prefix regexp: ", prefixPatStr)
+		return "", nil, false
+	}
+	if !prefixPat.MatchString(content) {
+		return "", nil, false
+	}
+	fs := strings.Fields(prefixPat.ReplaceAllString(content, ""))
+	if len(fs) == 0 {
+		return "help", nil, true
+	}
+	return fs[0], fs[1:], true
+}
+
+func langHandler(r *request, args []string) {
 	if len(args) == 0 {
 		// get language
-		lang, err := db.GetUserLanguage(m.Author.ID)
+		lang, err := db.GetUserLanguage(r.author.ID)
 		if err != nil {
-			log.Print("error get user ", m.Author.ID, "'s language: ", err.Error())
+			log.Print("error get user ", r.author.ID, "'s language: ", err.Error())
 			return
 		}
-		// User %s's language is %s.
-		msg := fmt.Sprintf("%s の読み上げ言語は %s です。", nick(s, m.GuildID, m.Author), lang)
-		if _, err := s.ChannelMessageSend(m.ChannelID, msg); err != nil {
-		}
+		r.reply(r.t(i18n.LangGet, "name", nick(r.s, r.guildID, r.author), "lang", lang))
 	} else {
 		// set language
 		lang := args[0]
-		if err := db.UpsertUserLanguage(m.Author.ID, lang); err != nil {
-			log.Print("error update user ", m.Author.ID, "'s language to ", lang, ": ", err.Error())
+		if err := db.UpsertUserLanguage(r.author.ID, lang); err != nil {
+			log.Print("error update user ", r.author.ID, "'s language to ", lang, ": ", err.Error())
 			return
 		}
-		// User %s's language is updated to %s
-		msg := fmt.Sprintf("%s の読み上げ言語を %s に変更しました。", nick(s, m.GuildID, m.Author), lang)
-		if _, err := s.ChannelMessageSend(m.ChannelID, msg); err != nil {
-		}
+		r.reply(r.t(i18n.LangSet, "name", nick(r.s, r.guildID, r.author), "lang", lang))
 	}
 }
 
-func randHandler(s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
+func randHandler(r *request, args []string) {
 	// generate random token and set for user
 	u, _ := uuid.NewUUID()
 	vt := u.String()
-	err := db.UpsertUserVoiceToken(m.Author.ID, vt)
+	err := db.UpsertUserVoiceToken(r.author.ID, vt)
 	if err != nil {
-		log.Print("error update user voice token ", m.Author.ID, "'s token: ", err.Error())
+		log.Print("error update user voice token ", r.author.ID, "'s token: ", err.Error())
 		return
 	}
 
 	// update voice token
-	if _, err := s.ChannelMessageSend(m.ChannelID, nick(s, m.GuildID, m.Author)+" の声を変更しました。"); err != nil {
-	}
+	r.reply(r.t(i18n.VoiceChanged, "name", nick(r.s, r.guildID, r.author)))
 
 	// play sample voice
 	var lang string
-	lang, err = db.GetUserLanguage(m.Author.ID)
+	lang, err = db.GetUserLanguage(r.author.ID)
 	if err != nil {
-		log.Print("error get user "+m.Author.ID+"'s langage: ", err)
+		log.Print("error get user "+r.author.ID+"'s langage: ", err)
 	}
 	if lang == "" {
 		lang = defaultTTSLang
 	}
 
-	ci, ok := consumers.Load(m.GuildID)
-	if ok {
-		c := ci.(*ttsConsumerBinding)
+	if c, ok := target(r); ok {
 		// Sample: hello
 		text := "サンプル: イカよろしく～"
-		c.consumer.Add(*worker.NewTask(fmt.Sprintf("Read %s in guild %s", text, m.GuildID),
-			func() error {
-				err := discord.Play(text, lang, vt, m.GuildID)
-				time.Sleep(100 * time.Millisecond)
-				return err
-			},
-		))
+		c.consumer.Add(*worker.NewSpeechTask(&worker.Speech{
+			GuildID:    r.guildID,
+			ChannelID:  r.channelID,
+			AuthorID:   r.author.ID,
+			AuthorName: nick(r.s, r.guildID, r.author),
+			Text:       text,
+			Lang:       lang,
+			VoiceToken: vt,
+			Priority:   systemPriority(r.guildID),
+		}))
+	}
+}
+
+// systemVoiceToken is the voice token to read text not written by members
+const systemVoiceToken = "yomiage"
+
+// speaker returns worker.Speaker of consumers which plays speech on VC of the guild the bot is joining
+func speaker(botID string) worker.Speaker {
+	return func(ctx context.Context, sp *worker.Speech) error {
+		// texts of merged messages are separated by newlines
+		text := strings.ReplaceAll(sp.Text, "\n", pause(sp.Lang))
+		if err := discord.Play(ctx, text, sp.Lang, sp.VoiceToken, sp.Rate, botID, sp.GuildID); err != nil {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+		return nil
 	}
 }
 
@@ -170,173 +225,165 @@ func nick(s *discordgo.Session, guildID string, m *discordgo.User) string {
 }
 
 type ttsConsumerBinding struct {
+	botID          string // user ID of the bot joining the VC
 	guildID        string
-	voiceChannelID string // VC to send voice
-	textChannelID  string // TC to read
+	voiceChannelID string // VC to send voice. guarded by mu because it changes when the bot is moved
+	textChannelID  string // TC on which the bot is summoned
 	consumer       *worker.Consumer
-	Cancel         func() // func to stop consumer
+
+	mu           sync.Mutex
+	textChannels map[string]bool // TCs to read
+	members      map[string]bool // IDs of members except bots in the VC
+	leaveTimer   *time.Timer     // not nil while waiting to leave after the last member left
+	rejoinTimer  *time.Timer     // not nil while waiting for the voice connection to be reconnected
 }
 
-// maps guildID to ttsConsumerBinding to read
+// maps sessionKey to ttsConsumerBinding to read
 // also works as flag whether the bot is working on a guild
 var consumers sync.Map
 
-func hiHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	authorID := m.Author.ID
-	guildID := m.GuildID
-
-	// Bot is not working on this guild?
-	if ci, ok := consumers.Load(guildID); ok {
-		c := ci.(*ttsConsumerBinding)
-		log.Printf("bot is already joining voice channel %s of this guild %s", c.voiceChannelID, m.GuildID)
-		ch, err := s.State.GuildChannel(guildID, m.ChannelID)
-		if err != nil {
-			log.Printf("error find guild %s channel %s", guildID, m.ChannelID)
-			return
-		}
-		// Already working on other channel
-		msg := fmt.Sprintf("すでにこのサーバーのボイスチャンネル %s を読み上げ中です。", ch.Name)
-		if _, err := s.ChannelMessageSend(m.ChannelID, msg); err != nil {
-			log.Print("error send message to channel ", m.ChannelID, " on guild ", guildID, ": ", err)
-		}
-		return
-	}
+func hiHandler(r *request) {
+	authorID := r.author.ID
+	guildID := r.guildID
 
 	// The command author is joining in a voice channel?
-	userVs, err := discord.VoiceState(s, authorID, guildID)
+	userVs, err := discord.VoiceState(r.s, authorID, guildID)
 	if err != nil {
 		log.Printf("failed to get VoiceState of guild %s: %s", guildID, err.Error())
 		return
 	}
 	if userVs == nil {
 		log.Printf("member %s is not joining any voice channel", authorID)
-		// Cannot summon the bot without joining any voice channel
-		if _, err := s.ChannelMessageSend(m.ChannelID, "ボイスチャンネルに参加せずに呼び出すことはできません。"); err != nil {
-			log.Print("error send message to channel ", m.ChannelID, " on guild ", guildID, ": ", err)
-		}
+		r.reply(r.t(i18n.NotInVoiceChannel))
 		return
 	}
 
-	// Ok, then start worker
-	ctx, cancel := context.WithCancel(context.Background())
-	wg := new(sync.WaitGroup) // now wg is not used. we can deleted it
-	consumer := worker.NewConsumer(guildID)
-	consumers.Store(guildID, &ttsConsumerBinding{
-		guildID:        m.GuildID,
-		voiceChannelID: userVs.ChannelID,
-		textChannelID:  m.ChannelID,
-		consumer:       consumer, // consumer should have cancel and wg should?
-		Cancel:         cancel,
-	})
+	// Any bot is not working on the voice channel?
+	for _, c := range guildBindings(guildID) {
+		if c.voiceChannel() != userVs.ChannelID {
+			continue
+		}
+		log.Printf("bot %s is already joining voice channel %s of this guild %s", c.botID, userVs.ChannelID, guildID)
+		ch, err := r.s.State.Channel(userVs.ChannelID)
+		if err != nil {
+			log.Printf("error find guild %s channel %s", guildID, userVs.ChannelID)
+			return
+		}
+		r.reply(r.t(i18n.AlreadyReading, "channel", ch.Name))
+		return
+	}
 
-	consumer.StartAsync(ctx, wg)
+	// Any bot is free on this guild?
+	s, ok := freeBot(guildID)
+	if !ok {
+		log.Printf("all bots are working on guild %s", guildID)
+		r.reply(r.t(i18n.NoFreeBot))
+		return
+	}
 
-	time.Sleep(200 * time.Millisecond) // waiting for bot to join voice channel
-	if msg := discord.JoinVC(s, m.GuildID, userVs.ChannelID); msg != "" {
-		if _, err := s.ChannelMessageSend(m.ChannelID, msg); err != nil {
-			log.Print("error send message to channel ", m.ChannelID, " on guild ", guildID, ": ", err)
-		}
+	// Ok, then start worker
+	msg, ok := startSession(s, guildID, userVs.ChannelID, r.channelID, r.lang())
+	if !ok {
+		log.Printf("bot %s has not started working on guild %s", s.State.User.ID, guildID)
+		return
+	}
+	if msg != "" {
+		r.reply(msg)
 	}
 }
 
-func byeHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	userID := m.Author.ID
-	botID := s.State.User.ID
-	guildID := m.GuildID
+func byeHandler(r *request) {
+	guildID := r.guildID
 
 	// Bot is working on this guild?
-	ci, ok := consumers.Load(guildID)
+	c, ok := target(r)
 	if !ok {
 		log.Print("not working on this guild ", guildID)
-		// Not working on this guild
-		if _, err := s.ChannelMessageSend(m.ChannelID, "現在このサーバーでは読み上げていません。"); err != nil {
-			log.Print("error send message to channel ", m.ChannelID, " on guild ", guildID, ": ", err)
-		}
+		r.reply(r.t(i18n.NotReading))
 		return
 	}
 
-	c := ci.(*ttsConsumerBinding)
+	// members who can manage the server can stop reading from anywhere
+	if !r.allowed(discordgo.PermissionManageServer) && !byeAllowed(r, c) {
+		return
+	}
 
-	// Text channel on which the commend was post is the working text channel of bot?
-	if m.ChannelID != c.textChannelID {
-		log.Printf("member %s is not joining voice channel bot is reading %s", userID, c.voiceChannelID)
-		thisCh, err := s.State.GuildChannel(guildID, m.ChannelID)
-		if err != nil {
-			log.Printf("error find guild %s channel %s", guildID, m.ChannelID)
-			return
-		}
-		wrkCh, err := s.State.GuildChannel(guildID, c.textChannelID)
+	// Ok, then stop worker
+	if msg, _ := stopSession(c.botID, r.guildID, r.lang()); msg != "" {
+		r.reply(msg)
+	}
+}
+
+// byeAllowed reports whether the author of r can stop reading of c, and tells the reason if not
+func byeAllowed(r *request, c *ttsConsumerBinding) bool {
+	userID := r.author.ID
+	botID := c.botID
+	guildID := r.guildID
+
+	// Text channel on which the commend was post is one of the working text channels of bot?
+	if !c.reads(r.s, r.channelID) {
+		log.Printf("member %s is not joining voice channel bot is reading %s", userID, c.voiceChannel())
+		thisCh, err := r.s.State.Channel(r.channelID)
 		if err != nil {
-			log.Printf("error find guild %s channel %s", guildID, m.ChannelID)
-			return
-		}
-		msg := fmt.Sprintf("このテキストチャンネル %s は読み上げていません。%s を読み上げ中です。", thisCh.Name, wrkCh.Name)
-		if _, err := s.ChannelMessageSend(m.ChannelID, msg); err != nil {
-			log.Print("error send message to channel ", m.ChannelID, " on guild ", guildID, ": ", err)
+			log.Printf("error find guild %s channel %s", guildID, r.channelID)
+			return false
 		}
-		return
+		r.reply(r.t(i18n.NotReadingChannel, "channel", thisCh.Name, "reading", c.textChannelNames()))
+		return false
 	}
 
 	// The command author is joining the working voice channel of bot?
-	userVs, err := discord.VoiceState(s, userID, guildID)
+	userVs, err := discord.VoiceState(r.s, userID, guildID)
 	if err != nil {
 		log.Printf("failed to get VoiceState of guild %s: %s", guildID, err.Error())
-		return
+		return false
 	}
 	if userVs == nil {
 		log.Printf("member %s is not joining any voice channel", userID)
-		// Cannot summon me without joining any voice channel
-		if _, err := s.ChannelMessageSend(m.ChannelID, "ボイスチャンネルに参加せずに呼び出すことはできません。"); err != nil {
-			log.Print("error send message to channel ", m.ChannelID, " on guild ", guildID, ": ", err)
-		}
-		return
+		r.reply(r.t(i18n.NotInVoiceChannel))
+		return false
 	}
-	if userVs.ChannelID != c.voiceChannelID {
-		log.Printf("member %s is not joining voice channel bot is reading %s", botID, c.voiceChannelID)
-		wrkCh, err := s.State.GuildChannel(guildID, c.textChannelID)
+	vcID := c.voiceChannel()
+	if userVs.ChannelID != vcID {
+		log.Printf("member %s is not joining voice channel bot is reading %s", botID, vcID)
+		wrkCh, err := r.s.State.Channel(vcID)
 		if err != nil {
-			log.Printf("error find guild %s channel %s", guildID, m.ChannelID)
-			return
-		}
-		msg := fmt.Sprintf("読み上げ中のボイスチャンネル %s に参加せずに読み上げを止めることはできません。", wrkCh.Name)
-		if _, err := s.ChannelMessageSend(m.ChannelID, msg); err != nil {
-			log.Print("error send message to channel ", m.ChannelID, " on guild ", guildID, ": ", err)
-		}
-		return
-	}
-
-	// Ok, then stop worker
-	consumers.Delete(m.GuildID)
-	c.Cancel()
-	if msg := discord.LeaveVC(m.GuildID); msg != "" {
-		if _, err := s.ChannelMessageSend(m.ChannelID, msg); err != nil {
-			log.Print("error send message to channel ", m.ChannelID, " on guild ", m.GuildID, ": ", err)
+			log.Printf("error find guild %s channel %s", guildID, vcID)
+			return false
 		}
+		r.reply(r.t(i18n.ByeFromOtherChannel, "channel", wrkCh.Name))
+		return false
 	}
-}
-
-func helpHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Usage: ...
-	msg := "使い方: https://github.com/tubo28/yomiage/blob/main/README.md"
-	if _, err := s.ChannelMessageSend(m.ChannelID, msg); err != nil {
-		log.Print("error send message to channel ", m.ChannelID, " on guild ", m.GuildID, ": ", err)
-	}
+	return true
 }
 
 const maxTTSLength = 50
 
 func nonCommandHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	ci, ok := consumers.Load(m.GuildID)
+	c, ok := binding(s.State.User.ID, m.GuildID)
 	if !ok {
 		log.Printf("not working on this guild %s. message is ignored", m.GuildID)
 		return
 	}
-	c := ci.(*ttsConsumerBinding)
-	if m.ChannelID != c.textChannelID {
-		log.Printf("bot is working but not reading this text channel%s. message is ignored", m.ChannelID)
+	if !c.reads(s, m.ChannelID) {
+		log.Printf("bot is working but not reading this text channel %s. message is ignored", m.ChannelID)
 		return
 	}
 
+	err := c.consumer.Add(readTask(s, m.Message))
+	if errors.Is(err, worker.ErrQueueFull) && guildSetting(m.GuildID, db.GuildOverflow) == overflowNotify && overflowCooldown.allow(m.GuildID) {
+		msg := i18n.T(uiLang(s, m.GuildID), i18n.QueueOverflow)
+		if _, err := s.ChannelMessageSend(m.ChannelID, msg); err != nil {
+			log.Print("error send message to channel ", m.ChannelID, " on guild ", m.GuildID, ": ", err)
+		}
+	}
+}
+
+// overflowCooldown limits notices of queue overflow keyed by guild ID
+var overflowCooldown = &cooldown{d: time.Minute}
+
+// readTask returns task to read out the message
+func readTask(s *discordgo.Session, m *discordgo.Message) worker.Task {
 	lang, err := db.GetUserLanguage(m.Author.ID)
 	if err != nil {
 		log.Print("error get user "+m.Author.ID+"'s langage: ", err)
@@ -353,6 +400,16 @@ func nonCommandHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
 		vt = m.Author.ID
 	}
 
+	// overrides of the channel take precedence over the author's
+	if l, v := channelOverride(s, m.ChannelID); l != "" || v != "" {
+		if l != "" {
+			lang = l
+		}
+		if v != "" {
+			vt = v
+		}
+	}
+
 	// TODO: trim ogg files by time
 	text := replaceMention(s, m)
 	text = Sanitize(text, lang)
@@ -360,13 +417,16 @@ func nonCommandHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
 		text = string(textR[:maxTTSLength]) + " 以下略" // following is omitted
 	}
 
-	c.consumer.Add(*worker.NewTask(fmt.Sprintf("Read %s in guild %s", text, m.GuildID),
-		func() error {
-			err := discord.Play(text, lang, vt, m.GuildID)
-			time.Sleep(100 * time.Millisecond)
-			return err
-		},
-	))
+	return *worker.NewSpeechTask(&worker.Speech{
+		GuildID:    m.GuildID,
+		ChannelID:  m.ChannelID,
+		MessageID:  m.ID,
+		AuthorID:   m.Author.ID,
+		AuthorName: nick(s, m.GuildID, m.Author),
+		Text:       text,
+		Lang:       lang,
+		VoiceToken: vt,
+	})
 }
 
 var (
@@ -382,7 +442,7 @@ var (
 // Differences:
 // - don't skip non-mentionable role
 // - add emoji replacement
-func replaceMention(s *discordgo.Session, m *discordgo.MessageCreate) (content string) {
+func replaceMention(s *discordgo.Session, m *discordgo.Message) (content string) {
 	content = m.Content
 
 	if !s.StateEnabled {
@@ -459,6 +519,14 @@ func replaceMention(s *discordgo.Session, m *discordgo.MessageCreate) (content s
 	return
 }
 
+// pause returns text to make a pause between sentences in lang
+func pause(lang string) string {
+	if strings.HasPrefix(lang, "ja-") || lang == "ja" {
+		return "。"
+	}
+	return ". "
+}
+
 // Sanitize modifies m.Content easier to read for bot in following steps:
 // 1. trim spaces
 // 2. replace continuous 'w's to kusa
@@ -485,39 +553,3 @@ func Sanitize(content, lang string) string {
 
 	return s
 }
-
-// cleanerWorker starts worker which clean up workers which is alone on voice channels
-func cleanerWorker() {
-	log.Print("start cleaner worker")
-	for {
-		time.Sleep(10 * time.Second)
-		cs := []*ttsConsumerBinding{}
-		consumers.Range(func(k interface{}, v interface{}) bool {
-			cs = append(cs, v.(*ttsConsumerBinding))
-			return true
-		})
-		for _, c := range cs {
-			time.Sleep(1 * time.Second)
-			if discord.Alone(c.guildID) {
-				log.Printf("bot is alone in voice channel on guild %s, leave", c.guildID)
-				consumers.Delete(c.guildID)
-				c.Cancel()
-				_ = discord.LeaveVC(c.guildID)
-			}
-		}
-	}
-}
-
-// cleanerWorkerEndless calls CleanerWorker and call it again if it panics
-func cleanerWorkerEndless() {
-	for {
-		func() {
-			defer func() {
-				if err := recover(); err != nil {
-					log.Println("CleanerWorker paniced unexpectedly: ", err)
-				}
-			}()
-			cleanerWorker()
-		}()
-	}
-}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/db"
+)
+
+// values of db.GuildEditBehavior
+const (
+	editBehaviorReplace = "replace" // read the edited text instead
+	editBehaviorSkip    = "skip"    // do not read the message
+)
+
+// messageDelete cancels reading of the message if it is still queued
+func messageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
+	c, ok := binding(s.State.User.ID, m.GuildID)
+	if !ok {
+		return
+	}
+	if c.consumer.Remove(m.ID) {
+		log.Printf("message %s on guild %s is deleted before read", m.ID, m.GuildID)
+	}
+}
+
+// messageDeleteBulk cancels reading of the messages if they are still queued
+func messageDeleteBulk(s *discordgo.Session, m *discordgo.MessageDeleteBulk) {
+	c, ok := binding(s.State.User.ID, m.GuildID)
+	if !ok {
+		return
+	}
+	for _, id := range m.Messages {
+		if c.consumer.Remove(id) {
+			log.Printf("message %s on guild %s is deleted before read", id, m.GuildID)
+		}
+	}
+}
+
+// messageUpdate replaces or cancels reading of the message if it is still queued
+func messageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println("recovered: ", err)
+		}
+	}()
+
+	c, ok := binding(s.State.User.ID, m.GuildID)
+	if !ok {
+		return
+	}
+	if !c.reads(s, m.ChannelID) {
+		return
+	}
+
+	// updates without author or content are not edits by members (e.g. embeds of URL are expanded)
+	if m.Author == nil || m.Content == "" {
+		return
+	}
+	if m.BeforeUpdate != nil && m.BeforeUpdate.Content == m.Content {
+		return
+	}
+
+	if guildSetting(m.GuildID, db.GuildEditBehavior) == editBehaviorSkip || ignoredPrefix(m.GuildID, guildSetting(m.GuildID, db.GuildPrefix), m.Content) {
+		if c.consumer.Remove(m.ID) {
+			log.Printf("message %s on guild %s is edited before read, skip", m.ID, m.GuildID)
+		}
+		return
+	}
+
+	if c.consumer.Replace(m.ID, readTask(s, m.Message)) {
+		log.Printf("message %s on guild %s is edited before read, replace", m.ID, m.GuildID)
+	}
+}
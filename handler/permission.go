@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/db"
+	"github.com/tubo28/yomiage/i18n"
+)
+
+// allowed reports whether the author of r has permission bits perm on the channel or one of admin roles of the guild
+func (r *request) allowed(perm int64) bool {
+	for _, id := range adminRoles(r.guildID) {
+		for _, role := range r.roles {
+			if role == id {
+				return true
+			}
+		}
+	}
+
+	p, err := r.permissions()
+	if err != nil {
+		log.Print("error get permissions of member ", r.author.ID, " on guild ", r.guildID, ": ", err)
+		return false
+	}
+	return p&perm == perm || p&discordgo.PermissionAdministrator != 0
+}
+
+// adminRoles returns IDs of roles which can run all commands on the guild
+func adminRoles(guildID string) []string {
+	return strings.Fields(guildSetting(guildID, db.GuildAdminRoles))
+}
+
+// deniedMessage returns message to tell the author of r that permission bits perm or admin roles are needed
+func deniedMessage(r *request, perm int64) string {
+	names := []string{}
+	for _, id := range adminRoles(r.guildID) {
+		if role, err := r.s.State.Role(r.guildID, id); err == nil {
+			names = append(names, "@"+role.Name)
+		}
+	}
+	pn := permissionName(r.lang(), perm)
+	if len(names) == 0 {
+		return r.t(i18n.NeedPermission, "permission", pn)
+	}
+	return r.t(i18n.NeedPermissionOrRole, "permission", pn, "roles", strings.Join(names, ", "))
+}
+
+// permissionNames are keys of names of permissions shown to users
+var permissionNames = map[int64]i18n.Key{
+	discordgo.PermissionManageServer: i18n.PermManageServer,
+}
+
+func permissionName(lang i18n.Lang, perm int64) string {
+	if k, ok := permissionNames[perm]; ok {
+		return i18n.T(lang, k)
+	}
+	return i18n.T(lang, i18n.PermAdministrator)
+}
+
+var roleMentionReg = regexp.MustCompile(`^<@&(\d+)>$`)
+
+// parseRoles returns space separated IDs of roles of the guild in val.
+// roles are given as mentions, IDs or names. ok is false if any of them is not found
+func parseRoles(s *discordgo.Session, guildID, val string) (ids string, ok bool) {
+	g, err := s.State.Guild(guildID)
+	if err != nil {
+		log.Print("error find guild ", guildID, ": ", err)
+		return "", false
+	}
+
+	res := []string{}
+	for _, f := range strings.Fields(val) {
+		if m := roleMentionReg.FindStringSubmatch(f); m != nil {
+			f = m[1]
+		}
+		found := false
+		for _, role := range g.Roles {
+			if role.ID == f || role.Name == strings.TrimPrefix(f, "@") {
+				res = append(res, role.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	return strings.Join(res, " "), true
+}
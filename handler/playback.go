@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tubo28/yomiage/i18n"
+	"github.com/tubo28/yomiage/worker"
+)
+
+// maxQueueListLength is the max number of tasks shown by !queue
+const maxQueueListLength = 10
+
+func playbackHandler(r *request, h func(r *request, c *ttsConsumerBinding) string) {
+	if c, ok := target(r); ok {
+		r.reply(h(r, c))
+	} else {
+		r.reply(r.t(i18n.NotReading))
+	}
+}
+
+func skipHandler(r *request, c *ttsConsumerBinding) string {
+	if !c.consumer.Skip() {
+		return r.t(i18n.NothingReading)
+	}
+	return r.t(i18n.Skipped)
+}
+
+func stopHandler(r *request, c *ttsConsumerBinding) string {
+	n := c.consumer.Clear()
+	c.consumer.Skip()
+	return r.t(i18n.Stopped, "count", strconv.Itoa(n))
+}
+
+func clearHandler(r *request, c *ttsConsumerBinding) string {
+	return r.t(i18n.Cleared, "count", strconv.Itoa(c.consumer.Clear()))
+}
+
+func pauseHandler(r *request, c *ttsConsumerBinding) string {
+	c.consumer.Pause()
+	return r.t(i18n.Paused)
+}
+
+func resumeHandler(r *request, c *ttsConsumerBinding) string {
+	c.consumer.Resume()
+	return r.t(i18n.Resumed)
+}
+
+func queueHandler(r *request, c *ttsConsumerBinding) string {
+	lines := []string{}
+	if c.consumer.Paused() {
+		lines = append(lines, r.t(i18n.QueuePaused))
+	}
+	if t, ok := c.consumer.Current(); ok {
+		lines = append(lines, "▶ "+taskSummary(t))
+	}
+	pending := c.consumer.Pending()
+	for i, t := range pending {
+		if i == maxQueueListLength {
+			lines = append(lines, r.t(i18n.QueueMore, "count", strconv.Itoa(len(pending)-maxQueueListLength)))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, taskSummary(t)))
+	}
+	if len(lines) == 0 {
+		return r.t(i18n.QueueEmpty)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func taskSummary(t worker.Task) string {
+	switch {
+	case t.Speech == nil:
+		return t.ID
+	case t.Speech.AuthorName == "":
+		return t.Speech.Text
+	default:
+		return t.Speech.AuthorName + ": " + t.Speech.Text
+	}
+}
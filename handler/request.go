@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/i18n"
+)
+
+// request is a command invoked by a text message or a slash command
+type request struct {
+	s           *discordgo.Session
+	guildID     string
+	channelID   string
+	author      *discordgo.User
+	roles       []string               // role IDs of the author
+	message     *discordgo.Message     // nil if invoked by a slash command
+	interaction *discordgo.Interaction // nil if invoked by a text message
+	ephemeral   bool                   // whether replies to the slash command are shown only to the author
+	replied     bool
+}
+
+func newMessageRequest(s *discordgo.Session, m *discordgo.MessageCreate) *request {
+	r := &request{
+		s:         s,
+		guildID:   m.GuildID,
+		channelID: m.ChannelID,
+		author:    m.Author,
+		message:   m.Message,
+	}
+	if m.Member != nil {
+		r.roles = m.Member.Roles
+	}
+	return r
+}
+
+func newInteractionRequest(s *discordgo.Session, i *discordgo.InteractionCreate, ephemeral bool) *request {
+	return &request{
+		s:           s,
+		guildID:     i.GuildID,
+		channelID:   i.ChannelID,
+		author:      i.Member.User,
+		roles:       i.Member.Roles,
+		interaction: i.Interaction,
+		ephemeral:   ephemeral,
+	}
+}
+
+// reply sends msg to the channel the command is invoked on.
+// for slash commands, the first reply fills the deferred response and following ones are sent as followups.
+func (r *request) reply(msg string) {
+	if r.interaction == nil {
+		if _, err := r.s.ChannelMessageSend(r.channelID, msg); err != nil {
+			log.Print("error send message to channel ", r.channelID, " on guild ", r.guildID, ": ", err)
+		}
+		return
+	}
+
+	if !r.replied {
+		r.replied = true
+		if _, err := r.s.InteractionResponseEdit(r.interaction, &discordgo.WebhookEdit{Content: &msg}); err != nil {
+			log.Print("error edit interaction response on guild ", r.guildID, ": ", err)
+		}
+		return
+	}
+
+	params := &discordgo.WebhookParams{Content: msg}
+	if r.ephemeral {
+		params.Flags = discordgo.MessageFlagsEphemeral
+	}
+	if _, err := r.s.FollowupMessageCreate(r.interaction, false, params); err != nil {
+		log.Print("error send followup message on guild ", r.guildID, ": ", err)
+	}
+}
+
+// done fills the deferred response of the slash command if nothing is replied
+func (r *request) done() {
+	if r.interaction != nil && !r.replied {
+		r.reply("OK")
+	}
+}
+
+// permissions returns discord permission bits of the author on the channel.
+// for text commands, they are computed from roles of the member sent with the message
+// since members are not cached without the privileged GuildMembers intent
+func (r *request) permissions() (int64, error) {
+	if r.interaction != nil && r.interaction.Member != nil {
+		return r.interaction.Member.Permissions, nil
+	}
+	if r.message != nil && r.message.Member != nil {
+		return r.s.State.MessagePermissions(r.message)
+	}
+	return r.s.State.UserChannelPermissions(r.author.ID, r.channelID)
+}
+
+// lang returns the language of messages on the guild
+func (r *request) lang() i18n.Lang {
+	return uiLang(r.s, r.guildID)
+}
+
+// t returns the message of key in the language of the guild
+func (r *request) t(key i18n.Key, args ...string) string {
+	return i18n.T(r.lang(), key, args...)
+}
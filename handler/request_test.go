@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestRequestPermissions(t *testing.T) {
+	state := discordgo.NewState()
+	err := state.GuildAdd(&discordgo.Guild{
+		ID:      "g",
+		OwnerID: "owner",
+		Roles: []*discordgo.Role{
+			{ID: "g", Permissions: discordgo.PermissionSendMessages}, // @everyone
+			{ID: "mod", Permissions: discordgo.PermissionManageServer},
+		},
+		Channels: []*discordgo.Channel{{ID: "c", GuildID: "g", Type: discordgo.ChannelTypeGuildText}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &discordgo.Session{State: state}
+
+	tests := []struct {
+		name  string
+		roles []string
+		want  int64
+	}{
+		{"everyone", []string{}, discordgo.PermissionSendMessages},
+		{"mod", []string{"mod"}, discordgo.PermissionSendMessages | discordgo.PermissionManageServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the member is not cached in the state
+			m := &discordgo.MessageCreate{Message: &discordgo.Message{
+				ChannelID: "c",
+				GuildID:   "g",
+				Author:    &discordgo.User{ID: "u"},
+				Member:    &discordgo.Member{Roles: tt.roles},
+			}}
+			got, err := newMessageRequest(s, m).permissions()
+			if err != nil {
+				t.Fatalf("permissions() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("permissions() = %b, want %b", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/db"
+	"github.com/tubo28/yomiage/discord"
+	"github.com/tubo28/yomiage/i18n"
+	"github.com/tubo28/yomiage/worker"
+)
+
+// startSession makes the bot of s join the voice channel and start reading the text channel and the text chat of the voice channel.
+// ok is false if the bot is already working on the guild or fails to join the voice channel
+func startSession(s *discordgo.Session, guildID, voiceChannelID, textChannelID string, lang i18n.Lang) (msg string, ok bool) {
+	botID := s.State.User.ID
+	consumer := worker.NewConsumer(guildID, speaker(botID))
+	c := &ttsConsumerBinding{
+		botID:          botID,
+		guildID:        guildID,
+		voiceChannelID: voiceChannelID,
+		textChannelID:  textChannelID,
+		consumer:       consumer,
+		textChannels:   map[string]bool{textChannelID: true, voiceChannelID: true},
+		members:        map[string]bool{},
+	}
+	// members are counted from the state only here and updated by events after that
+	for _, id := range humans(s, guildID, voiceChannelID) {
+		c.members[id] = true
+	}
+	if _, loaded := consumers.LoadOrStore(sessionKey{botID, guildID}, c); loaded {
+		return "", false
+	}
+	c.save()
+
+	consumer.SetPolicy(guildPolicy(guildID))
+	consumer.Start()
+
+	time.Sleep(200 * time.Millisecond) // waiting for bot to join voice channel
+	msg, err := discord.JoinVC(s, guildID, voiceChannelID, lang)
+	if err != nil {
+		log.Print("error start session of bot ", botID, " on guild ", guildID, ": ", err)
+		// the saved session is also deleted not to restore it
+		_, _ = stopSession(botID, guildID, lang) // message is not shown
+		return "", false
+	}
+	return msg, true
+}
+
+// stopSession makes the bot stop reading and leave the voice channel on the guild.
+// ok is false if the bot is not working on the guild
+func stopSession(botID, guildID string, lang i18n.Lang) (msg string, ok bool) {
+	ci, ok := consumers.LoadAndDelete(sessionKey{botID, guildID})
+	if !ok {
+		return "", false
+	}
+	c := ci.(*ttsConsumerBinding)
+	c.cancelLeave()
+	c.cancelRejoin()
+	c.consumer.Stop()
+	if err := db.DeleteSession(botID, guildID); err != nil {
+		log.Print("error delete session of bot ", botID, " on guild ", guildID, ": ", err)
+	}
+	return discord.LeaveVC(botID, guildID, lang), true
+}
+
+// save stores the session to restore it after restart
+func (c *ttsConsumerBinding) save() {
+	// the session may be stopped while changing
+	if cur, ok := binding(c.botID, c.guildID); !ok || cur != c {
+		return
+	}
+	err := db.UpsertSession(db.Session{
+		BotID:          c.botID,
+		GuildID:        c.guildID,
+		VoiceChannelID: c.voiceChannel(),
+		TextChannelID:  c.textChannelID,
+		TextChannelIDs: c.textChannelIDs(),
+	})
+	if err != nil {
+		log.Print("error save session of bot ", c.botID, " on guild ", c.guildID, ": ", err)
+	}
+}
+
+// guildCreate restores the session saved before restart if members are still in the voice channel
+func guildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	restoreSession(s, g.ID)
+}
+
+// restoreSessions restores sessions of guilds received before guildCreate is added as a handler.
+// guilds not received yet are restored by guildCreate
+func restoreSessions() {
+	for _, s := range discord.Sessions() {
+		s.State.RLock()
+		ids := []string{}
+		for _, g := range s.State.Guilds {
+			if !g.Unavailable {
+				ids = append(ids, g.ID)
+			}
+		}
+		s.State.RUnlock()
+		for _, id := range ids {
+			go restoreSession(s, id)
+		}
+	}
+}
+
+// restoreSession restores the session of the bot of s saved before restart if members are still in the voice channel
+func restoreSession(s *discordgo.Session, guildID string) {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println("recovered: ", err)
+		}
+	}()
+
+	if isClosing() {
+		return
+	}
+	// the session is alive if the guild is recovered from outage
+	botID := s.State.User.ID
+	if _, ok := binding(botID, guildID); ok {
+		return
+	}
+	saved, err := db.GetSession(botID, guildID)
+	if err != nil {
+		log.Print("error get session of bot ", botID, " on guild ", guildID, ": ", err)
+		return
+	}
+	if saved == nil {
+		return
+	}
+	if len(humans(s, guildID, saved.VoiceChannelID)) == 0 {
+		log.Printf("no members in voice channel %s on guild %s, don't restore session", saved.VoiceChannelID, guildID)
+		if err := db.DeleteSession(botID, guildID); err != nil {
+			log.Print("error delete session of bot ", botID, " on guild ", guildID, ": ", err)
+		}
+		return
+	}
+
+	log.Printf("restore session of bot %s in voice channel %s on guild %s", botID, saved.VoiceChannelID, guildID)
+	lang := uiLang(s, guildID)
+	if _, ok := startSession(s, guildID, saved.VoiceChannelID, saved.TextChannelID, lang); !ok {
+		return
+	}
+	c, ok := binding(botID, guildID)
+	if !ok {
+		return
+	}
+	// channels removed before restart are not read again
+	textChannels := map[string]bool{saved.TextChannelID: true}
+	for _, id := range saved.TextChannelIDs {
+		textChannels[id] = true
+	}
+	c.mu.Lock()
+	c.textChannels = textChannels
+	c.mu.Unlock()
+	c.save()
+	if _, err := s.ChannelMessageSend(saved.TextChannelID, i18n.T(lang, i18n.Reconnected)); err != nil {
+		log.Print("error send message to channel ", saved.TextChannelID, ": ", err)
+	}
+}
+
+// humans returns IDs of members except bots in the voice channel
+func humans(s *discordgo.Session, guildID, voiceChannelID string) []string {
+	g, err := s.State.Guild(guildID)
+	if err != nil {
+		log.Print("error find guild ", guildID, ": ", err)
+		return nil
+	}
+	ids := []string{}
+	for _, vs := range g.VoiceStates {
+		if vs.ChannelID == voiceChannelID && !isBot(s, guildID, vs.UserID) {
+			ids = append(ids, vs.UserID)
+		}
+	}
+	return ids
+}
+
+// updateMember records whether the member is in the voice channel and returns the number of members in it
+func (c *ttsConsumerBinding) updateMember(userID string, in bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if in {
+		c.members[userID] = true
+	} else {
+		delete(c.members, userID)
+	}
+	return len(c.members)
+}
+
+// memberCount returns the number of members except bots in the voice channel
+func (c *ttsConsumerBinding) memberCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.members)
+}
+
+// isBot reports whether the member is a bot
+func isBot(s *discordgo.Session, guildID, userID string) bool {
+	member, err := s.State.Member(guildID, userID)
+	if err != nil {
+		if member, err = s.GuildMember(guildID, userID); err != nil {
+			log.Print("error get member ", userID, " of guild ", guildID, ": ", err)
+			return false
+		}
+	}
+	return member.User.Bot
+}
+
+// scheduleLeave stops the session after idle timeout of the guild unless a member joins the voice channel again
+func (c *ttsConsumerBinding) scheduleLeave(s *discordgo.Session) {
+	timeout := time.Duration(guildSettingInt(c.guildID, db.GuildIdleTimeout)) * time.Second
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leaveTimer != nil {
+		return
+	}
+	log.Printf("no members in voice channel %s on guild %s, leave after %s", c.voiceChannelID, c.guildID, timeout)
+	c.leaveTimer = time.AfterFunc(timeout, func() {
+		c.mu.Lock()
+		c.leaveTimer = nil
+		c.mu.Unlock()
+
+		if c.memberCount() > 0 {
+			return
+		}
+		// the session may be replaced by a new one while waiting
+		if cur, ok := binding(c.botID, c.guildID); !ok || cur != c {
+			return
+		}
+		log.Printf("bot %s is alone in voice channel on guild %s, leave", c.botID, c.guildID)
+		_, _ = stopSession(c.botID, c.guildID, uiLang(s, c.guildID)) // message is not shown
+	})
+}
+
+// cancelLeave cancels scheduled leave
+func (c *ttsConsumerBinding) cancelLeave() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leaveTimer != nil {
+		c.leaveTimer.Stop()
+		c.leaveTimer = nil
+	}
+}
+
+// channelDelete stops the session if its VC is deleted, and stops reading deleted TCs
+func channelDelete(s *discordgo.Session, e *discordgo.ChannelDelete) {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println("recovered: ", err)
+		}
+	}()
+
+	if err := db.DeleteAutoJoinsOfChannel(e.ID); err != nil {
+		log.Print("error delete auto join rules of channel ", e.ID, ": ", err)
+	}
+
+	c, ok := binding(s.State.User.ID, e.GuildID)
+	if !ok {
+		return
+	}
+	if e.ID == c.voiceChannel() {
+		log.Printf("voice channel %s on guild %s is deleted, leave", e.ID, e.GuildID)
+		_, _ = stopSession(c.botID, e.GuildID, uiLang(s, e.GuildID)) // message is not shown
+		return
+	}
+	if c.removeTextChannel(e.ID) {
+		log.Printf("text channel %s on guild %s is deleted, stop reading it", e.ID, e.GuildID)
+	}
+}
+
+// guildDelete stops the session if the bot is removed from the guild
+func guildDelete(s *discordgo.Session, e *discordgo.GuildDelete) {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println("recovered: ", err)
+		}
+	}()
+
+	// the guild will be available again after the outage
+	if e.Unavailable {
+		return
+	}
+	if _, ok := stopSession(s.State.User.ID, e.ID, i18n.En); ok { // message is not shown
+		log.Printf("bot %s is removed from guild %s, stop reading", s.State.User.ID, e.ID)
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/db"
+	"github.com/tubo28/yomiage/discord"
+	"github.com/tubo28/yomiage/i18n"
+)
+
+// values of db.GuildShutdownNotice
+const (
+	shutdownNoticeOff   = "off"   // leave silently
+	shutdownNoticePost  = "post"  // post the notice to the text channel
+	shutdownNoticeSpeak = "speak" // read the notice on VC
+)
+
+// closing is 1 after Close is called. events are ignored while closing
+var closing int32
+
+func isClosing() bool {
+	return atomic.LoadInt32(&closing) == 1
+}
+
+// Close finishes reading queued messages until ctx is done and leaves voice channels on all guilds.
+// sessions are kept in the db to be restored after restart
+func Close(ctx context.Context) {
+	atomic.StoreInt32(&closing, 1)
+
+	var wg sync.WaitGroup
+	consumers.Range(func(_, ci interface{}) bool {
+		wg.Add(1)
+		go func(c *ttsConsumerBinding) {
+			defer wg.Done()
+			c.shutdown(ctx, discord.Session(c.botID, c.guildID))
+		}(ci.(*ttsConsumerBinding))
+		return true
+	})
+	wg.Wait()
+}
+
+// shutdown drains the consumer and leaves the voice channel without deleting the saved session
+func (c *ttsConsumerBinding) shutdown(ctx context.Context, s *discordgo.Session) {
+	// the session is not stopped by the bot leaving VC if it is not in consumers
+	consumers.Delete(sessionKey{c.botID, c.guildID})
+	c.cancelLeave()
+
+	lang := uiLang(s, c.guildID)
+	notice := guildSetting(c.guildID, db.GuildShutdownNotice)
+	msg := i18n.T(lang, i18n.ShutdownNotice)
+	log.Printf("drain consumer of bot %s on guild %s", c.botID, c.guildID)
+	c.consumer.Drain(ctx)
+
+	// spoken after queued messages are read not to keep reading after telling leaving
+	if notice == shutdownNoticeSpeak && ctx.Err() == nil {
+		if err := discord.Play(ctx, msg, systemTTSLang(lang), systemVoiceToken, 0, c.botID, c.guildID); err != nil {
+			log.Print("error speak shutdown notice on guild ", c.guildID, ": ", err)
+		}
+	}
+
+	if notice == shutdownNoticePost {
+		if _, err := s.ChannelMessageSend(c.textChannelID, msg); err != nil {
+			log.Print("error send message to channel ", c.textChannelID, ": ", err)
+		}
+	}
+	discord.LeaveVC(c.botID, c.guildID, lang)
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/i18n"
+	"github.com/tubo28/yomiage/tts"
+)
+
+// maxAutocompleteChoices is the max number of choices discord accepts for autocomplete
+const maxAutocompleteChoices = 25
+
+// slashCommands returns application commands of commands to register to discord
+func slashCommands() []*discordgo.ApplicationCommand {
+	dmPermission := false
+	cmds := []*discordgo.ApplicationCommand{}
+	for _, c := range commands {
+		ac := &discordgo.ApplicationCommand{
+			Name:                     c.name,
+			Description:              i18n.T(i18n.En, c.help),
+			DescriptionLocalizations: localizations(c.help),
+			DMPermission:             &dmPermission,
+		}
+		for _, a := range c.args {
+			o := &discordgo.ApplicationCommandOption{
+				Type:                     discordgo.ApplicationCommandOptionString,
+				Name:                     a.name,
+				Description:              i18n.T(i18n.En, a.description),
+				DescriptionLocalizations: *localizations(a.description),
+				Autocomplete:             a.complete != nil,
+			}
+			if a.choices != nil {
+				for _, v := range a.choices() {
+					o.Choices = append(o.Choices, &discordgo.ApplicationCommandOptionChoice{Name: v, Value: v})
+				}
+			}
+			ac.Options = append(ac.Options, o)
+		}
+		cmds = append(cmds, ac)
+	}
+	return cmds
+}
+
+// discordLocales maps languages to discord locales
+var discordLocales = map[i18n.Lang][]discordgo.Locale{
+	i18n.Ja: {discordgo.Japanese},
+	i18n.En: {discordgo.EnglishUS, discordgo.EnglishGB},
+}
+
+// localizations returns messages of key in discord locales
+func localizations(key i18n.Key) *map[discordgo.Locale]string {
+	m := map[discordgo.Locale]string{}
+	for _, l := range i18n.Langs {
+		for _, loc := range discordLocales[l] {
+			m[loc] = i18n.T(l, key)
+		}
+	}
+	return &m
+}
+
+func interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println("recovered: ", err)
+		}
+	}()
+
+	if i.GuildID == "" || i.Member == nil || isClosing() {
+		return
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		slashCommand(s, i)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		autocomplete(s, i)
+	}
+}
+
+func slashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	c, ok := commandIndex[data.Name]
+	if !ok {
+		log.Print("unknown slash command: ", data.Name)
+		return
+	}
+
+	// respond first because handlers may take longer than 3 seconds discord waits for
+	resp := &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{},
+	}
+	if c.ephemeral {
+		resp.Data.Flags = discordgo.MessageFlagsEphemeral
+	}
+	if err := s.InteractionRespond(i.Interaction, resp); err != nil {
+		log.Print("error respond to slash command ", data.Name, " on guild ", i.GuildID, ": ", err)
+		return
+	}
+
+	r := newInteractionRequest(s, i, c.ephemeral)
+	c.run(r, slashArgs(c, data.Options))
+	r.done()
+}
+
+// slashArgs returns values of options as args in the defined order.
+// missing options are passed as empty strings to keep positions of the following ones,
+// and trailing ones are dropped as if omitted in the text command
+func slashArgs(c *command, options []*discordgo.ApplicationCommandInteractionDataOption) []string {
+	args := make([]string, len(c.args))
+	for i, a := range c.args {
+		for _, o := range options {
+			if o.Name == a.name {
+				args[i] = fmt.Sprint(o.Value)
+			}
+		}
+	}
+	for len(args) > 0 && args[len(args)-1] == "" {
+		args = args[:len(args)-1]
+	}
+	return args
+}
+
+func autocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	c, ok := commandIndex[data.Name]
+	if !ok {
+		return
+	}
+
+	var complete func() []string
+	input := ""
+	for _, o := range data.Options {
+		if !o.Focused {
+			continue
+		}
+		input = strings.ToLower(o.StringValue())
+		for _, a := range c.args {
+			if a.name == o.Name {
+				complete = a.complete
+			}
+		}
+	}
+	if complete == nil {
+		return
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, v := range complete() {
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(v), input) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: v, Value: v})
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		log.Print("error respond to autocomplete on guild ", i.GuildID, ": ", err)
+	}
+}
+
+var (
+	langCodesOnce sync.Once
+	langCodes     []string
+)
+
+// languageCodes returns language codes for autocomplete. they are fetched from TTS API only once
+func languageCodes() []string {
+	langCodesOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		codes, err := tts.LanguageCodes(ctx)
+		if err != nil {
+			log.Print("error get language codes: ", err)
+			return
+		}
+		langCodes = codes
+	})
+	return langCodes
+}
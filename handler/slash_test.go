@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestSlashArgs(t *testing.T) {
+	c := commandIndex["autojoin"]
+	opt := func(name, value string) *discordgo.ApplicationCommandInteractionDataOption {
+		return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionString, Value: value}
+	}
+	tests := []struct {
+		name    string
+		options []*discordgo.ApplicationCommandInteractionDataOption
+		want    []string
+	}{
+		{"none", nil, []string{}},
+		{"all", []*discordgo.ApplicationCommandInteractionDataOption{opt("action", "set"), opt("voice", "v"), opt("text", "t")}, []string{"set", "v", "t"}},
+		{"unordered", []*discordgo.ApplicationCommandInteractionDataOption{opt("text", "t"), opt("action", "set"), opt("voice", "v")}, []string{"set", "v", "t"}},
+		{"middle missing", []*discordgo.ApplicationCommandInteractionDataOption{opt("action", "set"), opt("text", "t")}, []string{"set", "", "t"}},
+		{"trailing missing", []*discordgo.ApplicationCommandInteractionDataOption{opt("action", "set"), opt("voice", "v")}, []string{"set", "v"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slashArgs(c, tt.options); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("slashArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
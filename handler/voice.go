@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tubo28/yomiage/db"
+	"github.com/tubo28/yomiage/i18n"
+	"github.com/tubo28/yomiage/worker"
+)
+
+// announceCooldown limits announcements of the same kind for a member keyed by "guildID/userID/setting".
+// it prevents the bot from reading out every join and leave of a member whose connection is flapping.
+var announceCooldown = &cooldown{d: 10 * time.Second}
+
+func voiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println("recovered: ", err)
+		}
+	}()
+
+	if isClosing() {
+		return
+	}
+
+	if v.UserID == s.State.User.ID {
+		botVoiceStateUpdate(s, v)
+		return
+	}
+
+	if c, ok := binding(s.State.User.ID, v.GuildID); ok {
+		memberVoiceStateUpdate(s, c, v)
+	}
+	// one of free bots joins if the member is the first one in VC with auto join rule
+	if primaryBot(v.GuildID) == s.State.User.ID {
+		autoJoin(s, v)
+	}
+}
+
+// memberVoiceStateUpdate counts members in VC of the session and announces their joins and leaves
+func memberVoiceStateUpdate(s *discordgo.Session, c *ttsConsumerBinding, v *discordgo.VoiceStateUpdate) {
+	if follows(c, v) {
+		return
+	}
+
+	vcID := c.voiceChannel()
+	before := v.BeforeUpdate
+	wasIn := before != nil && before.ChannelID == vcID
+	isIn := v.ChannelID == vcID
+
+	if !memberIsBot(s, v) {
+		if c.updateMember(v.UserID, isIn) == 0 {
+			c.scheduleLeave(s)
+		} else {
+			c.cancelLeave()
+		}
+	}
+
+	var gs db.GuildSetting
+	switch {
+	case isIn && !wasIn:
+		gs = db.GuildJoinTemplate
+	case !isIn && wasIn:
+		gs = db.GuildLeaveTemplate
+	case isIn && v.SelfStream && !before.SelfStream:
+		gs = db.GuildStreamTemplate
+	default:
+		return
+	}
+
+	lang := uiLang(s, v.GuildID)
+	tmpl := announceTemplate(lang, v.GuildID, gs)
+	if tmpl == "off" {
+		return
+	}
+	if !announceCooldown.allow(v.GuildID + "/" + v.UserID + "/" + string(gs)) {
+		log.Printf("announcement %s of member %s on guild %s is rate limited", gs, v.UserID, v.GuildID)
+		return
+	}
+
+	text := strings.ReplaceAll(tmpl, "{name}", nick(s, v.GuildID, voiceUser(s, v)))
+	c.consumer.Add(*worker.NewSpeechTask(&worker.Speech{
+		GuildID:    v.GuildID,
+		ChannelID:  c.textChannelID,
+		Text:       text,
+		Lang:       systemTTSLang(lang),
+		VoiceToken: systemVoiceToken,
+		Priority:   systemPriority(v.GuildID),
+	}))
+}
+
+// memberIsBot reports whether the member of the voice state is a bot
+func memberIsBot(s *discordgo.Session, v *discordgo.VoiceStateUpdate) bool {
+	if v.Member != nil && v.Member.User != nil {
+		return v.Member.User.Bot
+	}
+	return isBot(s, v.GuildID, v.UserID)
+}
+
+// voiceUser returns the user of the voice state
+func voiceUser(s *discordgo.Session, v *discordgo.VoiceStateUpdate) *discordgo.User {
+	if v.Member != nil && v.Member.User != nil {
+		return v.Member.User
+	}
+	member, err := s.GuildMember(v.GuildID, v.UserID)
+	if err != nil {
+		log.Print("error get member ", v.UserID, " of guild ", v.GuildID, ": ", err)
+		return &discordgo.User{ID: v.UserID}
+	}
+	return member.User
+}
+
+// announceTemplates are keys of default templates of announcements in the UI language
+var announceTemplates = map[db.GuildSetting]i18n.Key{
+	db.GuildJoinTemplate:   i18n.JoinTemplate,
+	db.GuildLeaveTemplate:  i18n.LeaveTemplate,
+	db.GuildStreamTemplate: i18n.StreamTemplate,
+}
+
+// announceTemplate returns the template of announcement gs on the guild, or the default one in lang if not configured
+func announceTemplate(lang i18n.Lang, guildID string, gs db.GuildSetting) string {
+	v, err := db.GetGuildSetting(guildID, gs)
+	if err != nil {
+		log.Print("error get guild ", guildID, "'s setting ", gs, ": ", err)
+	}
+	if v == "" {
+		return i18n.T(lang, announceTemplates[gs])
+	}
+	return v
+}
@@ -0,0 +1,91 @@
+package i18n
+
+var en = map[Key]string{
+	CmdHi:            "Join your voice channel and start reading",
+	CmdBye:           "Stop reading and leave the voice channel",
+	CmdHelp:          "Show usage",
+	CmdLang:          "Show or change the language to read your text",
+	CmdLangCode:      "Language code (e.g. en-US)",
+	CmdVoice:         "Randomize your voice",
+	CmdConfig:        "Show or change settings of the server",
+	CmdConfigKey:     "Setting",
+	CmdConfigValue:   "Value to set (default resets it)",
+	CmdChannel:       "Set text channels to read, and language and voice of each channel",
+	CmdChannelAction: "list, add, remove, lang or voice",
+	CmdChannelValue:  "Channel for add and remove, language code for lang (default unsets it)",
+	CmdSkip:          "Skip the message being read",
+	CmdStop:          "Stop reading and remove waiting messages",
+	CmdClear:         "Remove waiting messages",
+	CmdPause:         "Pause reading",
+	CmdResume:        "Resume reading",
+	CmdQueue:         "Show waiting messages",
+
+	HelpForms:            "Commands are also available as slash commands or by mentioning the bot.",
+	HelpDetails:          "Details: {url}",
+	UnknownCommand:       "Unknown command {command}. Use help to show usage.",
+	DidYouMean:           "Unknown command {command}. Did you mean {suggestion}?",
+	NeedPermission:       "{permission} permission is needed to run this command.",
+	NeedPermissionOrRole: "{permission} permission or role {roles} is needed to run this command.",
+	PermManageServer:     "Manage Server",
+	PermAdministrator:    "Administrator",
+	AlreadyReading:       "Already reading voice channel {channel} of this server.",
+	NoFreeBot:            "All reading bots are busy in other voice channels.",
+	NotInVoiceChannel:    "Join a voice channel to call me.",
+	NotReading:           "Not reading on this server.",
+	NotReadingChannel:    "Not reading this text channel {channel}. Reading {reading}.",
+	ByeFromOtherChannel:  "Join voice channel {channel} being read to stop reading.",
+	QueueOverflow:        "Too many messages are waiting. New messages are not read for a while.",
+	Reconnected:          "Reconnected.",
+	ShutdownNotice:       "Disconnecting for maintenance. The bot will reconnect after restart.",
+	LangGet:              "Language of {name} is {lang}.",
+	LangSet:              "Language of {name} is changed to {lang}.",
+	VoiceChanged:         "Voice of {name} is changed.",
+	ConfigUnknown:        "No such setting {key}.",
+	ConfigChoices:        "Value of {key} must be one of {choices}.",
+	ConfigRange:          "Value of {key} must be an integer from {min} to {max}.",
+	ConfigPrefix:         "Value of {key} must be at most {max} characters without spaces.",
+	ConfigMember:         "Value of {key} must be a mention or an ID of a member of this server.",
+	ConfigRoles:          "Value of {key} must be mentions, IDs or names of roles of this server.",
+	ConfigUpdated:        "{key} is changed to {value}.",
+	ChannelList:          "Reading text channels: {channels}",
+	ChannelAdded:         "Started reading {channel}.",
+	ChannelRemoved:       "Stopped reading {channel}.",
+	ChannelNotRead:       "Not reading {channel}.",
+	ChannelNotFound:      "Channel {channel} is not found.",
+	ChannelUnknownAction: "Cannot {action}. Specify one of {actions}.",
+	ChannelLangGet:       "Language of {channel} is {lang}.",
+	ChannelLangSet:       "Language of {channel} is changed to {lang}.",
+	ChannelLangNotSet:    "Language of each member is used on {channel}.",
+	ChannelVoiceSet:      "Voice of {channel} is changed.",
+	ChannelVoiceReset:    "Voice of each member is used on {channel}.",
+	NothingReading:       "Nothing is being read.",
+	Skipped:              "Skipped.",
+	Stopped:              "Stopped reading and removed {count} waiting messages.",
+	Cleared:              "Removed {count} waiting messages.",
+	Paused:               "Paused.",
+	Resumed:              "Resumed.",
+	QueuePaused:          "(paused)",
+	QueueMore:            "and {count} more",
+	QueueEmpty:           "No messages are waiting.",
+	JoinAlreadyHere:      "Already in the voice channel. Something is wrong.",
+	Joined:               "I read text here.",
+	LeaveNotJoined:       "Not in any voice channel of this server. Something is wrong.",
+	Left:                 "Bye",
+
+	CmdAutoJoin:       "Set voice channels to start reading automatically when a member joins",
+	CmdAutoJoinAction: "list, add or remove",
+	CmdAutoJoinVoice:  "Voice channel",
+	CmdAutoJoinText:   "Text channel to read (this channel if omitted)",
+
+	AutoJoinEmpty:          "No voice channels to join automatically.",
+	AutoJoinRule:           "{voice} → {text}",
+	AutoJoinNoVoiceChannel: "Specify a voice channel.",
+	AutoJoinNotFound:       "Not joining {voice} automatically.",
+	AutoJoinAdded:          "I start reading {text} when a member joins {voice}.",
+	AutoJoinRemoved:        "I no longer join {voice} automatically.",
+	AutoJoinUnknownAction:  "Cannot {action}. Specify one of {actions}.",
+
+	JoinTemplate:   "{name} joined",
+	LeaveTemplate:  "{name} left",
+	StreamTemplate: "{name} started streaming",
+}
@@ -0,0 +1,161 @@
+package i18n
+
+import (
+	"strings"
+)
+
+// Lang is a language of messages shown to users
+type Lang string
+
+const (
+	Ja Lang = "ja" // Japanese
+	En Lang = "en" // English
+)
+
+// Langs is the list of all languages which have catalogs
+var Langs = []Lang{Ja, En}
+
+// fallback is used for messages missing in the catalog of a language
+const fallback = Ja
+
+// Key identifies a message in catalogs
+type Key string
+
+// descriptions of commands
+const (
+	CmdHi            Key = "cmd_hi"
+	CmdBye           Key = "cmd_bye"
+	CmdHelp          Key = "cmd_help"
+	CmdLang          Key = "cmd_lang"
+	CmdLangCode      Key = "cmd_lang_code"
+	CmdVoice         Key = "cmd_voice"
+	CmdConfig        Key = "cmd_config"
+	CmdConfigKey     Key = "cmd_config_key"
+	CmdConfigValue   Key = "cmd_config_value"
+	CmdChannel       Key = "cmd_channel"
+	CmdChannelAction Key = "cmd_channel_action"
+	CmdChannelValue  Key = "cmd_channel_value"
+	CmdSkip          Key = "cmd_skip"
+	CmdStop          Key = "cmd_stop"
+	CmdClear         Key = "cmd_clear"
+	CmdPause         Key = "cmd_pause"
+	CmdResume        Key = "cmd_resume"
+	CmdQueue         Key = "cmd_queue"
+)
+
+// replies and notices. comments are placeholders of the message
+const (
+	HelpForms            Key = "help_forms"
+	HelpDetails          Key = "help_details"            // {url}
+	UnknownCommand       Key = "unknown_command"         // {command}
+	DidYouMean           Key = "did_you_mean"            // {command}, {suggestion}
+	NeedPermission       Key = "need_permission"         // {permission}
+	NeedPermissionOrRole Key = "need_permission_or_role" // {permission}, {roles}
+	PermManageServer     Key = "perm_manage_server"
+	PermAdministrator    Key = "perm_administrator"
+	AlreadyReading       Key = "already_reading" // {channel}
+	NoFreeBot            Key = "no_free_bot"
+	NotInVoiceChannel    Key = "not_in_vc"
+	NotReading           Key = "not_reading"
+	NotReadingChannel    Key = "not_reading_tc"    // {channel}, {reading}
+	ByeFromOtherChannel  Key = "bye_from_other_vc" // {channel}
+	QueueOverflow        Key = "queue_overflow"
+	Reconnected          Key = "reconnected"
+	ShutdownNotice       Key = "shutdown_notice"
+	LangGet              Key = "lang_get"               // {name}, {lang}
+	LangSet              Key = "lang_set"               // {name}, {lang}
+	VoiceChanged         Key = "voice_changed"          // {name}
+	ConfigUnknown        Key = "config_unknown"         // {key}
+	ConfigChoices        Key = "config_choices"         // {key}, {choices}
+	ConfigRange          Key = "config_range"           // {key}, {min}, {max}
+	ConfigPrefix         Key = "config_prefix"          // {key}, {max}
+	ConfigMember         Key = "config_member"          // {key}
+	ConfigRoles          Key = "config_roles"           // {key}
+	ConfigUpdated        Key = "config_updated"         // {key}, {value}
+	ChannelList          Key = "channel_list"           // {channels}
+	ChannelAdded         Key = "channel_added"          // {channel}
+	ChannelRemoved       Key = "channel_removed"        // {channel}
+	ChannelNotRead       Key = "channel_not_read"       // {channel}
+	ChannelNotFound      Key = "channel_not_found"      // {channel}
+	ChannelUnknownAction Key = "channel_unknown_action" // {action}, {actions}
+	ChannelLangGet       Key = "channel_lang_get"       // {channel}, {lang}
+	ChannelLangSet       Key = "channel_lang_set"       // {channel}, {lang}
+	ChannelLangNotSet    Key = "channel_lang_not_set"   // {channel}
+	ChannelVoiceSet      Key = "channel_voice_set"      // {channel}
+	ChannelVoiceReset    Key = "channel_voice_reset"    // {channel}
+	NothingReading       Key = "nothing_reading"
+	Skipped              Key = "skipped"
+	Stopped              Key = "stopped" // {count}
+	Cleared              Key = "cleared" // {count}
+	Paused               Key = "paused"
+	Resumed              Key = "resumed"
+	QueuePaused          Key = "queue_paused"
+	QueueMore            Key = "queue_more" // {count}
+	QueueEmpty           Key = "queue_empty"
+	JoinAlreadyHere      Key = "join_already_here"
+	Joined               Key = "joined"
+	LeaveNotJoined       Key = "leave_not_joined"
+	Left                 Key = "left"
+)
+
+// descriptions of autojoin command
+const (
+	CmdAutoJoin       Key = "cmd_autojoin"
+	CmdAutoJoinAction Key = "cmd_autojoin_action"
+	CmdAutoJoinVoice  Key = "cmd_autojoin_voice"
+	CmdAutoJoinText   Key = "cmd_autojoin_text"
+)
+
+// replies of autojoin command. comments are placeholders of the message
+const (
+	AutoJoinEmpty          Key = "autojoin_empty"
+	AutoJoinRule           Key = "autojoin_rule" // {voice}, {text}
+	AutoJoinNoVoiceChannel Key = "autojoin_no_vc"
+	AutoJoinNotFound       Key = "autojoin_not_found"      // {voice}
+	AutoJoinAdded          Key = "autojoin_added"          // {voice}, {text}
+	AutoJoinRemoved        Key = "autojoin_removed"        // {voice}
+	AutoJoinUnknownAction  Key = "autojoin_unknown_action" // {action}, {actions}
+)
+
+// default templates of announcements read on VC
+const (
+	JoinTemplate   Key = "join_template"   // {name}
+	LeaveTemplate  Key = "leave_template"  // {name}
+	StreamTemplate Key = "stream_template" // {name}
+)
+
+var catalogs = map[Lang]map[Key]string{
+	Ja: ja,
+	En: en,
+}
+
+// T returns the message of key in lang.
+// args are pairs of a placeholder name and its value, e.g. T(lang, LangGet, "name", "tubo28", "lang", "ja-JP")
+func T(lang Lang, key Key, args ...string) string {
+	msg, ok := catalogs[lang][key]
+	if !ok {
+		if msg, ok = catalogs[fallback][key]; !ok {
+			msg = string(key)
+		}
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		msg = strings.ReplaceAll(msg, "{"+args[i]+"}", args[i+1])
+	}
+	return msg
+}
+
+// Parse returns Lang named s
+func Parse(s string) (Lang, bool) {
+	for _, l := range Langs {
+		if string(l) == s {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// FromLocale returns Lang of discord locale such as "ja" or "en-US"
+func FromLocale(locale string) (Lang, bool) {
+	base := strings.SplitN(locale, "-", 2)[0]
+	return Parse(strings.ToLower(base))
+}
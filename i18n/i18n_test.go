@@ -0,0 +1,91 @@
+package i18n
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+var placeholderReg = regexp.MustCompile(`\{[a-z]+\}`)
+
+func placeholders(msg string) []string {
+	ps := placeholderReg.FindAllString(msg, -1)
+	sort.Strings(ps)
+	return ps
+}
+
+func TestCatalogs(t *testing.T) {
+	for _, l := range Langs {
+		c := catalogs[l]
+		if len(c) != len(catalogs[fallback]) {
+			t.Errorf("catalog %s has %d messages, want %d", l, len(c), len(catalogs[fallback]))
+		}
+		for k, want := range catalogs[fallback] {
+			msg, ok := c[k]
+			if !ok {
+				t.Errorf("catalog %s doesn't have %s", l, k)
+				continue
+			}
+			if got := placeholders(msg); !reflect.DeepEqual(got, placeholders(want)) {
+				t.Errorf("placeholders of %s in catalog %s = %v, want %v", k, l, got, placeholders(want))
+			}
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		name string
+		lang Lang
+		key  Key
+		args []string
+		want string
+	}{
+		{
+			name: "placeholders should be replaced",
+			lang: En,
+			key:  LangSet,
+			args: []string{"name", "abc", "lang", "ja-JP"},
+			want: "Language of abc is changed to ja-JP.",
+		},
+		{
+			name: "unknown language should fall back",
+			lang: Lang("xx"),
+			key:  Skipped,
+			want: "スキップしました。",
+		},
+		{
+			name: "unknown key should be returned as is",
+			lang: En,
+			key:  Key("no_such_key"),
+			want: "no_such_key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := T(tt.lang, tt.key, tt.args...); got != tt.want {
+				t.Errorf("T() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromLocale(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   Lang
+		ok     bool
+	}{
+		{"ja", Ja, true},
+		{"en-US", En, true},
+		{"en-GB", En, true},
+		{"fr", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := FromLocale(tt.locale)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FromLocale(%s) = %v, %v, want %v, %v", tt.locale, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package i18n
+
+var ja = map[Key]string{
+	CmdHi:            "ボイスチャンネルに参加して読み上げを開始します",
+	CmdBye:           "読み上げを終了してボイスチャンネルから退出します",
+	CmdHelp:          "使い方を表示します",
+	CmdLang:          "読み上げ言語を表示または変更します",
+	CmdLangCode:      "言語コード (例: ja-JP)",
+	CmdVoice:         "声をランダムに変更します",
+	CmdConfig:        "サーバーの設定を表示または変更します",
+	CmdConfigKey:     "設定項目",
+	CmdConfigValue:   "設定する値 (default で初期値に戻します)",
+	CmdChannel:       "読み上げるテキストチャンネルや、チャンネルごとの言語と声を設定します",
+	CmdChannelAction: "list, add, remove, lang または voice",
+	CmdChannelValue:  "add, remove はチャンネル、lang は言語コード (default で解除します)",
+	CmdSkip:          "読み上げ中のメッセージをスキップします",
+	CmdStop:          "読み上げを中止して待機中のメッセージを削除します",
+	CmdClear:         "待機中のメッセージを削除します",
+	CmdPause:         "読み上げを一時停止します",
+	CmdResume:        "読み上げを再開します",
+	CmdQueue:         "待機中のメッセージを表示します",
+
+	HelpForms:            "コマンドはスラッシュコマンドやボットへのメンションでも実行できます。",
+	HelpDetails:          "詳細: {url}",
+	UnknownCommand:       "コマンド {command} はありません。help で使い方を表示します。",
+	DidYouMean:           "コマンド {command} はありません。もしかして {suggestion} ですか？",
+	NeedPermission:       "このコマンドの実行には {permission} 権限が必要です。",
+	NeedPermissionOrRole: "このコマンドの実行には {permission} 権限またはロール {roles} が必要です。",
+	PermManageServer:     "サーバー管理",
+	PermAdministrator:    "管理者",
+	AlreadyReading:       "すでにこのサーバーのボイスチャンネル {channel} を読み上げ中です。",
+	NoFreeBot:            "すべての読み上げボットが他のボイスチャンネルで使用中です。",
+	NotInVoiceChannel:    "ボイスチャンネルに参加せずに呼び出すことはできません。",
+	NotReading:           "現在このサーバーでは読み上げていません。",
+	NotReadingChannel:    "このテキストチャンネル {channel} は読み上げていません。{reading} を読み上げ中です。",
+	ByeFromOtherChannel:  "読み上げ中のボイスチャンネル {channel} に参加せずに読み上げを止めることはできません。",
+	QueueOverflow:        "読み上げ待ちのメッセージが多すぎるため、しばらく新しいメッセージは読み上げません。",
+	Reconnected:          "再接続しました。",
+	ShutdownNotice:       "メンテナンスのため切断します。再起動後に再接続します。",
+	LangGet:              "{name} の読み上げ言語は {lang} です。",
+	LangSet:              "{name} の読み上げ言語を {lang} に変更しました。",
+	VoiceChanged:         "{name} の声を変更しました。",
+	ConfigUnknown:        "設定項目 {key} はありません。",
+	ConfigChoices:        "{key} の値は {choices} のいずれかです。",
+	ConfigRange:          "{key} の値は {min} 以上 {max} 以下の整数です。",
+	ConfigPrefix:         "{key} の値は空白を含まない {max} 文字以下の文字列です。",
+	ConfigMember:         "{key} の値はこのサーバーのメンバーのメンションまたは ID です。",
+	ConfigRoles:          "{key} の値はこのサーバーのロールのメンション、ID または名前です。",
+	ConfigUpdated:        "{key} を {value} に変更しました。",
+	ChannelList:          "読み上げ中のテキストチャンネル: {channels}",
+	ChannelAdded:         "{channel} の読み上げを開始しました。",
+	ChannelRemoved:       "{channel} の読み上げを終了しました。",
+	ChannelNotRead:       "{channel} は読み上げていません。",
+	ChannelNotFound:      "チャンネル {channel} が見つかりません。",
+	ChannelUnknownAction: "{action} はできません。{actions} のいずれかを指定してください。",
+	ChannelLangGet:       "{channel} の読み上げ言語は {lang} です。",
+	ChannelLangSet:       "{channel} の読み上げ言語を {lang} に変更しました。",
+	ChannelLangNotSet:    "{channel} ではメンバーごとの読み上げ言語を使います。",
+	ChannelVoiceSet:      "{channel} の声を変更しました。",
+	ChannelVoiceReset:    "{channel} ではメンバーごとの声を使います。",
+	NothingReading:       "読み上げ中のメッセージはありません。",
+	Skipped:              "スキップしました。",
+	Stopped:              "読み上げを中止し、待機中のメッセージ {count} 件を削除しました。",
+	Cleared:              "待機中のメッセージ {count} 件を削除しました。",
+	Paused:               "一時停止しました。",
+	Resumed:              "再開しました。",
+	QueuePaused:          "(一時停止中)",
+	QueueMore:            "他 {count} 件",
+	QueueEmpty:           "待機中のメッセージはありません。",
+	JoinAlreadyHere:      "すでにボイスチャンネルにいます。何かがおかしいです。",
+	Joined:               "読み上げます。",
+	LeaveNotJoined:       "このサーバーにbotが参加しているボイスチャンネルがありません。何かがおかしいです。",
+	Left:                 "さようなら",
+
+	CmdAutoJoin:       "メンバーがボイスチャンネルに参加したときに自動で読み上げを開始する設定をします",
+	CmdAutoJoinAction: "list, add または remove",
+	CmdAutoJoinVoice:  "ボイスチャンネル",
+	CmdAutoJoinText:   "読み上げるテキストチャンネル (省略するとこのチャンネル)",
+
+	AutoJoinEmpty:          "自動参加するボイスチャンネルはありません。",
+	AutoJoinRule:           "{voice} → {text}",
+	AutoJoinNoVoiceChannel: "ボイスチャンネルを指定してください。",
+	AutoJoinNotFound:       "{voice} には自動参加しません。",
+	AutoJoinAdded:          "{voice} にメンバーが参加したら {text} の読み上げを開始します。",
+	AutoJoinRemoved:        "{voice} に自動参加しないようにしました。",
+	AutoJoinUnknownAction:  "{action} はできません。{actions} のいずれかを指定してください。",
+
+	JoinTemplate:   "{name}さんが入室しました",
+	LeaveTemplate:  "{name}さんが退室しました",
+	StreamTemplate: "{name}さんが配信を開始しました",
+}
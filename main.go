@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/tubo28/yomiage/db"
@@ -13,6 +18,23 @@ import (
 	"github.com/tubo28/yomiage/tts"
 )
 
+// defaultShutdownTimeout is the max time to finish reading queued messages before exit unless SHUTDOWN_TIMEOUT is set
+const defaultShutdownTimeout = 10 * time.Second
+
+// shutdownTimeout returns the max time to finish reading queued messages before exit
+func shutdownTimeout() time.Duration {
+	v := os.Getenv("SHUTDOWN_TIMEOUT")
+	if v == "" {
+		return defaultShutdownTimeout
+	}
+	sec, err := strconv.Atoi(v)
+	if err != nil || sec < 0 {
+		log.Printf("invalid SHUTDOWN_TIMEOUT: %s. use default %s", v, defaultShutdownTimeout)
+		return defaultShutdownTimeout
+	}
+	return time.Duration(sec) * time.Second
+}
+
 func main() {
 	tts.Init()
 	defer tts.Close()
@@ -25,12 +47,23 @@ func main() {
 
 	handler.Init()
 
+	// metrics are served on /debug/vars by expvar
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		go func() {
+			log.Print("error serving metrics: ", http.ListenAndServe(addr, nil))
+		}()
+	}
+
 	// Wait here until CTRL-C or other term signal is received.
 	fmt.Println("yomiage is now running. press CTRL-C to exit.")
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
 	<-sc
-	fmt.Println("Exit.")
+	fmt.Println("Shutting down...")
 
-	os.Exit(0)
+	// Close of discord, db and tts are deferred
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
+	handler.Close(ctx)
+	fmt.Println("Exit.")
 }
@@ -0,0 +1,61 @@
+package tts
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EngineGoogle is the name of Google Cloud Text-to-Speech engine
+const EngineGoogle = "google"
+
+// defaultConcurrency is the number of concurrent requests to an engine if not configured
+const defaultConcurrency = 4
+
+// metrics of synthesis published on /debug/vars
+var metrics = expvar.NewMap("tts")
+
+// pools maps engine name to pool shared by all guilds
+var pools = map[string]*pool{}
+
+// pool limits the number of concurrent synthesis requests to an engine
+type pool struct {
+	engine string
+	sem    chan struct{}
+}
+
+// newPool returns pool of the engine whose size is TTS_CONCURRENCY_<ENGINE> environment variable
+func newPool(engine string) *pool {
+	env := "TTS_CONCURRENCY_" + strings.ToUpper(engine)
+	size := defaultConcurrency
+	if v := os.Getenv(env); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 {
+			log.Printf("invalid %s: %s. use default %d", env, v, defaultConcurrency)
+		} else {
+			size = n
+		}
+	}
+	log.Printf("synthesis concurrency of %s is %d", engine, size)
+	return &pool{engine: engine, sem: make(chan struct{}, size)}
+}
+
+// acquire blocks until a slot of the pool is available and returns func to release it
+func (p *pool) acquire(ctx context.Context) (release func(), err error) {
+	start := time.Now()
+	metrics.Add(p.engine+"_waiting", 1)
+	defer metrics.Add(p.engine+"_waiting", -1)
+
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	metrics.Add(p.engine+"_requests", 1)
+	metrics.Add(p.engine+"_wait_ms_total", time.Since(start).Milliseconds())
+	return func() { <-p.sem }, nil
+}
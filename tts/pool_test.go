@@ -0,0 +1,67 @@
+package tts
+
+import (
+	"context"
+	"expvar"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewPool(t *testing.T) {
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{"", defaultConcurrency},
+		{"2", 2},
+		{"0", defaultConcurrency},
+		{"abc", defaultConcurrency},
+	}
+	for _, tt := range tests {
+		os.Setenv("TTS_CONCURRENCY_TEST", tt.env)
+		if got := cap(newPool("test").sem); got != tt.want {
+			t.Errorf("size of pool with TTS_CONCURRENCY_TEST=%q = %d, want %d", tt.env, got, tt.want)
+		}
+	}
+	os.Unsetenv("TTS_CONCURRENCY_TEST")
+}
+
+// metric returns the value of the counter in metrics, 0 if not added yet
+func metric(name string) int64 {
+	if v, ok := metrics.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestPoolAcquire(t *testing.T) {
+	p := &pool{engine: "test_acquire", sem: make(chan struct{}, 1)}
+	// counters are global and kept over runs of the test
+	requests := metric("test_acquire_requests")
+	release, err := p.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the pool is full, so acquire waits until ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("acquire() on full pool = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	release()
+	release2, err := p.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire() after release = %v", err)
+	}
+	release2()
+
+	if got := metric("test_acquire_requests") - requests; got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+	if got := metric("test_acquire_waiting"); got != 0 {
+		t.Errorf("waiting = %d, want 0", got)
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"hash/fnv"
 	"io"
 	"log"
+	"math"
 	"math/rand"
+	"sort"
 
 	gtts "cloud.google.com/go/texttospeech/apiv1"
 	"github.com/jonas747/ogg"
@@ -19,6 +21,9 @@ var (
 	ttsClient *gtts.Client
 )
 
+// maxSpeakingRate is the max speaking_rate accepted by Google TTS API
+const maxSpeakingRate = 4.0
+
 // Init initializes Google TTS client
 func Init() {
 	var err error
@@ -26,6 +31,7 @@ func Init() {
 	if err != nil {
 		log.Fatal("failed to create tts client: ", err.Error())
 	}
+	pools[EngineGoogle] = newPool(EngineGoogle)
 }
 
 // Close closes client
@@ -41,7 +47,8 @@ func hash(s string) int64 {
 	return int64(h.Sum64() / 2)
 }
 
-func ttsReq(text, lang, voiceToken string) *gtts_pb.SynthesizeSpeechRequest {
+// ttsReq returns request of voice selected by voiceToken. its speaking rate is multiplied by rate
+func ttsReq(text, lang, voiceToken string, rate float64) *gtts_pb.SynthesizeSpeechRequest {
 	req := &gtts_pb.SynthesizeSpeechRequest{
 		Input: &gtts_pb.SynthesisInput{
 			InputSource: &gtts_pb.SynthesisInput_Text{Text: text},
@@ -62,17 +69,26 @@ func ttsReq(text, lang, voiceToken string) *gtts_pb.SynthesizeSpeechRequest {
 	req.AudioConfig.SpeakingRate = rs[r.Intn(len(rs))]
 	req.AudioConfig.Pitch = ps[r.Intn(len(ps))]
 
+	if rate > 0 {
+		req.AudioConfig.SpeakingRate = math.Min(req.AudioConfig.SpeakingRate*rate, maxSpeakingRate)
+	}
+
 	return req
 }
 
-// OGGGoogle call Google Cloud TTS API
-func OGGGoogle(text, lang, voiceToken string) ([][]byte, error) {
+// OGGGoogle call Google Cloud TTS API. rate is multiplier of speaking rate, 0 means 1
+func OGGGoogle(ctx context.Context, text, lang, voiceToken string, rate float64) ([][]byte, error) {
 	if len(text) == 0 {
 		return nil, fmt.Errorf("empty text")
 	}
-	req := ttsReq(text, lang, voiceToken)
+	req := ttsReq(text, lang, voiceToken, rate)
 
-	resp, err := ttsClient.SynthesizeSpeech(context.TODO(), req)
+	release, err := pools[EngineGoogle].acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for synthesis pool: %w", err)
+	}
+	resp, err := ttsClient.SynthesizeSpeech(ctx, req)
+	release()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ogg, received error from google api: %w", err)
 	}
@@ -97,3 +113,24 @@ func makeOGGBuffer(in []byte) (output [][]byte, err error) {
 		output = append(output, packet)
 	}
 }
+
+// LanguageCodes returns language codes supported by Google TTS API in ascending order
+func LanguageCodes(ctx context.Context) ([]string, error) {
+	resp, err := ttsClient.ListVoices(ctx, &gtts_pb.ListVoicesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list voices, received error from google api: %w", err)
+	}
+
+	seen := map[string]bool{}
+	codes := []string{}
+	for _, v := range resp.Voices {
+		for _, c := range v.LanguageCodes {
+			if !seen[c] {
+				seen[c] = true
+				codes = append(codes, c)
+			}
+		}
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
@@ -0,0 +1,220 @@
+package worker
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schedule is the order to run queued tasks
+type Schedule int
+
+const (
+	FIFO       Schedule = iota // in the order added
+	RoundRobin                 // one task of each author in turn. tasks of the same author are in the order added
+)
+
+// queue holds tasks of a consumer.
+// tasks of higher priority are run first. among tasks of the same priority,
+// each task is given a round when added, and tasks of smaller round are run first on RoundRobin.
+// the round of a task is the next of the last round run or the last round of the same author,
+// whichever is later, so authors with many tasks don't make others wait.
+type queue struct {
+	entries []entry // in the order added
+	seq     uint64  // seq of the last added entry
+	round   uint64  // round of the last popped entry
+}
+
+type entry struct {
+	task  Task
+	seq   uint64
+	round uint64
+}
+
+func taskAuthor(t Task) string {
+	if t.Speech == nil {
+		return ""
+	}
+	return t.Speech.AuthorID
+}
+
+func taskPriority(t Task) int {
+	if t.Speech == nil {
+		return PriorityNormal
+	}
+	return t.Speech.Priority
+}
+
+func (q *queue) len() int {
+	return len(q.entries)
+}
+
+func (q *queue) push(t Task) {
+	round := q.round
+	author := taskAuthor(t)
+	for _, e := range q.entries {
+		if taskAuthor(e.task) == author && e.round > round {
+			round = e.round
+		}
+	}
+	q.seq++
+	q.entries = append(q.entries, entry{task: t, seq: q.seq, round: round + 1})
+}
+
+// less reports whether a should run before b
+func less(s Schedule, a, b entry) bool {
+	if pa, pb := taskPriority(a.task), taskPriority(b.task); pa != pb {
+		return pa > pb
+	}
+	if s == RoundRobin && a.round != b.round {
+		return a.round < b.round
+	}
+	return a.seq < b.seq
+}
+
+// peek returns the index of the task to run next, or -1 if empty
+func (q *queue) peek(s Schedule) int {
+	if len(q.entries) == 0 {
+		return -1
+	}
+	next := 0
+	for i := range q.entries {
+		if less(s, q.entries[i], q.entries[next]) {
+			next = i
+		}
+	}
+	return next
+}
+
+func (q *queue) pop(s Schedule) (entry, bool) {
+	i := q.peek(s)
+	if i < 0 {
+		return entry{}, false
+	}
+	return q.take([]int{i}), true
+}
+
+// take removes entries at indices is and returns them merged into the first one.
+// texts of speeches are joined with a newline.
+func (q *queue) take(is []int) entry {
+	e := q.entries[is[0]]
+	if len(is) > 1 {
+		sp := *e.task.Speech
+		texts := []string{}
+		for _, i := range is {
+			texts = append(texts, q.entries[i].task.Speech.Text)
+		}
+		sp.Text = strings.Join(texts, "\n")
+		e.task = *NewSpeechTask(&sp)
+	}
+
+	taken := map[int]bool{}
+	for _, i := range is {
+		taken[i] = true
+	}
+	rest := q.entries[:0]
+	for i, x := range q.entries {
+		if !taken[i] {
+			rest = append(rest, x)
+		}
+	}
+	q.entries = rest
+
+	if e.round > q.round {
+		q.round = e.round
+	}
+	return e
+}
+
+// coalescible returns indices of entries[i] and following speeches which can be read with it at once.
+// they are speeches of the same author and voice added one after another without any other task between them,
+// each within window after the previous one.
+func (q *queue) coalescible(i int, window time.Duration) []int {
+	is := []int{i}
+	base := q.entries[i].task.Speech
+	if window <= 0 || base == nil || base.AuthorID == "" {
+		return is
+	}
+	for j := i + 1; j < len(q.entries); j++ {
+		prev, next := q.entries[j-1], q.entries[j]
+		sp := next.task.Speech
+		if next.seq != prev.seq+1 || sp == nil ||
+			sp.AuthorID != base.AuthorID || sp.Lang != base.Lang || sp.VoiceToken != base.VoiceToken || sp.Priority != base.Priority ||
+			sp.EnqueuedAt.Sub(prev.task.Speech.EnqueuedAt) > window {
+			break
+		}
+		is = append(is, j)
+	}
+	return is
+}
+
+// requeue adds back e popped before. it keeps its place since seq and round are not changed
+func (q *queue) requeue(e entry) {
+	q.entries = append(q.entries, e)
+	sort.SliceStable(q.entries, func(i, j int) bool { return q.entries[i].seq < q.entries[j].seq })
+}
+
+// popOldest removes the task added first
+func (q *queue) popOldest() (Task, bool) {
+	if len(q.entries) == 0 {
+		return Task{}, false
+	}
+	t := q.entries[0].task
+	q.entries = q.entries[1:]
+	return t, true
+}
+
+// list returns tasks in the order to be run
+func (q *queue) list(s Schedule) []Task {
+	ts := make([]Task, 0, len(q.entries))
+	for _, i := range q.order(s) {
+		ts = append(ts, q.entries[i].task)
+	}
+	return ts
+}
+
+// order returns indices of entries in the order to be run
+func (q *queue) order(s Schedule) []int {
+	is := make([]int, len(q.entries))
+	for i := range is {
+		is[i] = i
+	}
+	sort.Slice(is, func(i, j int) bool { return less(s, q.entries[is[i]], q.entries[is[j]]) })
+	return is
+}
+
+// find returns the index of the first task f returns true for, or -1
+func (q *queue) find(f func(t Task) bool) int {
+	for i := range q.entries {
+		if f(q.entries[i].task) {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove removes tasks f returns true for and returns them
+func (q *queue) remove(f func(t Task) bool) []Task {
+	removed := []Task{}
+	rest := q.entries[:0]
+	for _, e := range q.entries {
+		if f(e.task) {
+			removed = append(removed, e.task)
+			continue
+		}
+		rest = append(rest, e)
+	}
+	q.entries = rest
+	return removed
+}
+
+// countAuthor returns the number of queued tasks of the author
+func (q *queue) countAuthor(author string) int {
+	n := 0
+	for _, e := range q.entries {
+		if taskAuthor(e.task) == author {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func speechTask(author, text string) Task {
+	return *NewSpeechTask(&Speech{AuthorID: author, Text: text})
+}
+
+func texts(ts []Task) []string {
+	res := []string{}
+	for _, t := range ts {
+		res = append(res, t.Speech.Text)
+	}
+	return res
+}
+
+func TestQueueOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		want     []string
+	}{
+		{
+			name:     "fifo should keep the order added",
+			schedule: FIFO,
+			want:     []string{"a1", "a2", "a3", "b1", "c1", "b2"},
+		},
+		{
+			name:     "round robin should take one task of each author in turn",
+			schedule: RoundRobin,
+			want:     []string{"a1", "b1", "c1", "a2", "b2", "a3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := queue{}
+			for _, task := range []Task{
+				speechTask("a", "a1"),
+				speechTask("a", "a2"),
+				speechTask("a", "a3"),
+				speechTask("b", "b1"),
+				speechTask("c", "c1"),
+				speechTask("b", "b2"),
+			} {
+				q.push(task)
+			}
+
+			if got := texts(q.list(tt.schedule)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("list() = %v, want %v", got, tt.want)
+			}
+			popped := []Task{}
+			for {
+				e, ok := q.pop(tt.schedule)
+				if !ok {
+					break
+				}
+				popped = append(popped, e.task)
+			}
+			if got := texts(popped); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pop() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueueRoundRobinLateAuthor(t *testing.T) {
+	q := queue{}
+	q.push(speechTask("a", "a1"))
+	q.push(speechTask("a", "a2"))
+	q.push(speechTask("a", "a3"))
+	if e, _ := q.pop(RoundRobin); e.task.Speech.Text != "a1" {
+		t.Fatalf("pop() = %s, want a1", e.task.Speech.Text)
+	}
+
+	// author added after some tasks are run should not wait for all tasks of others
+	q.push(speechTask("b", "b1"))
+	want := []string{"a2", "b1", "a3"}
+	if got := texts(q.list(RoundRobin)); !reflect.DeepEqual(got, want) {
+		t.Errorf("list() = %v, want %v", got, want)
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	q := queue{}
+	q.push(speechTask("a", "a1"))
+	q.push(speechTask("a", "a2"))
+	q.push(speechTask("b", "b1"))
+	sys := speechTask("", "sys")
+	sys.Speech.Priority = PriorityHigh
+	q.push(sys)
+
+	e, _ := q.pop(RoundRobin)
+	if e.task.Speech.Text != "sys" {
+		t.Fatalf("pop() = %s, want sys", e.task.Speech.Text)
+	}
+	e, _ = q.pop(RoundRobin)
+	if e.task.Speech.Text != "a1" {
+		t.Fatalf("pop() = %s, want a1", e.task.Speech.Text)
+	}
+
+	// requeued task should be run first again
+	q.requeue(e)
+	want := []string{"a1", "b1", "a2"}
+	if got := texts(q.list(RoundRobin)); !reflect.DeepEqual(got, want) {
+		t.Errorf("list() = %v, want %v", got, want)
+	}
+}
+
+func TestQueueCoalescible(t *testing.T) {
+	now := time.Now()
+	at := func(task Task, d time.Duration) Task {
+		task.Speech.EnqueuedAt = now.Add(d)
+		return task
+	}
+
+	q := queue{}
+	q.push(at(speechTask("a", "a1"), 0))
+	q.push(at(speechTask("a", "a2"), 300*time.Millisecond))
+	q.push(at(speechTask("a", "a3"), 600*time.Millisecond))
+	q.push(at(speechTask("a", "a4"), 3*time.Second)) // too late
+	q.push(at(speechTask("b", "b1"), 3*time.Second))
+	q.push(at(speechTask("a", "a5"), 3*time.Second)) // another author's message between
+
+	is := q.coalescible(0, time.Second)
+	if want := []int{0, 1, 2}; !reflect.DeepEqual(is, want) {
+		t.Fatalf("coalescible() = %v, want %v", is, want)
+	}
+	if e := q.take(is); e.task.Speech.Text != "a1\na2\na3" {
+		t.Errorf("take() = %q, want %q", e.task.Speech.Text, "a1\na2\na3")
+	}
+	if want := []string{"a4", "b1", "a5"}; !reflect.DeepEqual(texts(q.list(FIFO)), want) {
+		t.Errorf("list() = %v, want %v", texts(q.list(FIFO)), want)
+	}
+	if is := q.coalescible(0, time.Second); len(is) != 1 {
+		t.Errorf("coalescible() = %v, want [0]", is)
+	}
+}
@@ -2,73 +2,703 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"fmt"
 	"log"
+	"runtime/debug"
 	"sync"
+	"time"
 )
 
 const taskQueueCapacity = 32
 
-// Task executed async
+// ErrStopped is returned by Consumer.Add after the consumer is stopped or while it is drained
+var ErrStopped = errors.New("consumer is stopped")
+
+// ErrQueueFull is returned by Consumer.Add when the task is discarded because the queue is full
+var ErrQueueFull = errors.New("task queue is full")
+
+// ErrAuthorQueueFull is returned by Consumer.Add when the speech is discarded because
+// its author has too many queued speeches
+var ErrAuthorQueueFull = errors.New("too many tasks of the author are queued")
+
+// metrics of all consumers published on /debug/vars
+var metrics = expvar.NewMap("worker")
+
+const (
+	metricDroppedOverflow = "dropped_overflow" // number of tasks discarded because the queue was full
+	metricDroppedAuthor   = "dropped_author"   // number of speeches discarded because the author had too many queued
+	metricDroppedStale    = "dropped_stale"    // number of speeches skipped because they waited too long
+	metricPanics          = "panics"           // number of tasks panicked
+	metricTimeouts        = "timeouts"         // number of tasks cancelled by TaskTimeout
+	metricRestarts        = "restarts"         // number of consumers restarted after panic
+)
+
+// restartInterval is the interval to restart consumer panicked
+const restartInterval = time.Second
+
+// Overflow is the behavior when a task is added to the full queue
+type Overflow int
+
+const (
+	DropNewest Overflow = iota // discard the added task
+	DropOldest                 // discard the oldest queued task and add the new one
+)
+
+// Policy is configuration of the queue of Consumer
+type Policy struct {
+	Capacity     int
+	Overflow     Overflow
+	MaxAge       time.Duration // speech waited longer than this is skipped. 0 means no limit
+	Schedule     Schedule
+	MaxPerAuthor int // max number of queued speeches of an author. 0 means no limit
+
+	// CoalesceWindow is the max interval of speeches of an author read at once.
+	// a speech is read after this duration passes with no following speech of the author,
+	// so the following ones can be merged. 0 disables it.
+	// texts of merged speeches are joined with a newline, which Speaker should read as a pause.
+	CoalesceWindow time.Duration
+
+	// MaxRate is the ceiling of the multiplier of speaking rate.
+	// speaking rate is raised as the queue gets longer or speeches wait longer. 1 or less disables it.
+	MaxRate float64
+
+	// TaskTimeout is the deadline of context of each task excluding time paused. 0 means no limit.
+	// it prevents a task blocked forever (e.g. on dead voice connection) from stopping the consumer.
+	TaskTimeout time.Duration
+}
+
+// speaking rate is raised by these for each queued task and each second the speech waited
+const (
+	rateStepPerTask   = 0.05
+	rateStepPerSecond = 0.01
+)
+
+// adaptiveRate returns multiplier of speaking rate for a speech which waited age with depth tasks queued after it.
+// it is 1 if maxRate is not more than 1.
+func adaptiveRate(maxRate float64, depth int, age time.Duration) float64 {
+	if maxRate <= 1 {
+		return 1
+	}
+	r := 1 + rateStepPerTask*float64(depth) + rateStepPerSecond*age.Seconds()
+	if r > maxRate {
+		return maxRate
+	}
+	return r
+}
+
+// maxCoalesceWaitRatio limits waiting for following speeches to CoalesceWindow times this
+const maxCoalesceWaitRatio = 3
+
+// DefaultPolicy is the policy of consumers created by NewConsumer
+var DefaultPolicy = Policy{Capacity: taskQueueCapacity, Overflow: DropNewest, Schedule: RoundRobin, TaskTimeout: 2 * time.Minute}
+
+// Speech is a job to read out text on VC
+type Speech struct {
+	GuildID    string
+	ChannelID  string // text channel the text is posted to
+	MessageID  string // empty if the text is not a message of members. e.g. announcements
+	AuthorID   string
+	AuthorName string
+	Text       string
+	Lang       string
+	VoiceToken string
+	EnqueuedAt time.Time // set by Consumer.Add if zero
+	Priority   int       // one of Priority* constants
+	Rate       float64   // multiplier of speaking rate of the voice set by Consumer. 0 means 1
+}
+
+// priorities of Speech
+const (
+	PriorityNormal    = 0  // messages of members
+	PriorityHigh      = 10 // run before tasks of lower priority
+	PriorityInterrupt = 20 // run before tasks of lower priority, interrupting the running one which is read again later
+)
+
+// Speaker reads out speech. it should return soon after ctx is done
+type Speaker func(ctx context.Context, s *Speech) error
+
+// Task executed async.
+// Speech is read by Speaker of the consumer if it is not nil, otherwise Do is called.
 type Task struct {
-	ID string
-	Do func() error
+	ID     string
+	Speech *Speech
+	Do     func(ctx context.Context) error
 }
 
-func NewTask(ID string, Do func() error) *Task {
+// NewTask returns task to run arbitrary func
+func NewTask(ID string, Do func(ctx context.Context) error) *Task {
 	return &Task{ID: ID, Do: Do}
 }
 
+// NewSpeechTask returns task to read s
+func NewSpeechTask(s *Speech) *Task {
+	return &Task{
+		ID:     fmt.Sprintf("Read %q of %s in guild %s", s.Text, s.AuthorID, s.GuildID),
+		Speech: s,
+	}
+}
+
+// messageID returns ID of the message read by the task, or empty string
+func (t *Task) messageID() string {
+	if t.Speech == nil {
+		return ""
+	}
+	return t.Speech.MessageID
+}
+
+// Consumer runs queued tasks one by one on its goroutine.
+// it is started by Start, and stopped by Stop. tasks can be added before it is started.
 type Consumer struct {
-	ID    string
-	queue chan Task
+	ID     string
+	speak  Speaker
+	ctx    context.Context // done when the consumer is stopped
+	stop   context.CancelFunc
+	done   chan struct{} // closed when the goroutine exits
+	mu     sync.Mutex
+	policy Policy
+	queue  queue
+	notify chan struct{} // receives a value when a task is added
+
+	started  bool
+	stopped  bool
+	draining bool // whether Drain is called. tasks are rejected
+
+	current       *entry             // running task, nil if idle
+	cancelCurrent context.CancelFunc // cancels context of the running task
+	preempted     bool               // whether the running task is cancelled to run a task of higher priority
+	skipped       bool               // whether the running task is cancelled by Skip
+	paused        bool
+	resumed       chan struct{} // closed when the consumer is resumed
+	pausing       chan struct{} // closed when the consumer is paused
 }
 
-func NewConsumer(ID string) *Consumer {
+func NewConsumer(ID string, speak Speaker) *Consumer {
+	ctx, stop := context.WithCancel(context.Background())
 	return &Consumer{
-		ID:    ID,
-		queue: make(chan Task, taskQueueCapacity),
+		ID:      ID,
+		speak:   speak,
+		ctx:     ctx,
+		stop:    stop,
+		done:    make(chan struct{}),
+		policy:  DefaultPolicy,
+		notify:  make(chan struct{}, 1),
+		pausing: make(chan struct{}),
 	}
 }
 
-func (c *Consumer) StartAsync(ctx context.Context, wg *sync.WaitGroup) {
-	wg.Add(1)
+type consumerKey struct{}
+
+// Start starts goroutine to run tasks until the consumer is stopped.
+// if the consumer panics, it is restarted keeping the queue. calling it twice or after Stop does nothing.
+func (c *Consumer) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started || c.stopped {
+		return
+	}
+	c.started = true
+
+	ctx := c.ctx
 	go func() {
-	L:
-		for {
+		defer close(c.done)
+		for !c.loop(ctx) {
+			metrics.Add(metricRestarts, 1)
 			select {
 			case <-ctx.Done():
-				break L
-			default:
+			case <-time.After(restartInterval):
+				log.Printf("restart consumer %s", c.ID)
 			}
+		}
 
-			task, ok := <-c.queue
-			if !ok {
-				log.Printf("task queue of consumer %s is closed", c.ID)
-				break L
+		c.discard()
+		log.Printf("consume %s is killed", c.ID)
+	}()
+}
+
+// Stop stops the consumer. the running task is cancelled, queued tasks are discarded
+// and tasks added after this are rejected. it doesn't wait for the goroutine to exit.
+func (c *Consumer) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return
+	}
+	c.stopped = true
+	c.stop()
+	if !c.started {
+		// no goroutine to discard tasks and to wait for
+		c.queue.remove(func(Task) bool { return true })
+		close(c.done)
+	}
+}
+
+// drainInterval is the interval to check whether the drained consumer finished tasks
+const drainInterval = 100 * time.Millisecond
+
+// Drain rejects tasks added after this and waits until queued tasks are finished, then stops the consumer.
+// if ctx is done before that, or the consumer is paused or not started, remaining tasks are discarded like Stop.
+// it returns after the goroutine exits
+func (c *Consumer) Drain(ctx context.Context) {
+	c.mu.Lock()
+	c.draining = true
+	c.mu.Unlock()
+
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for !c.idle() {
+		select {
+		case <-ctx.Done():
+			log.Printf("drain consumer %s: %v", c.ID, ctx.Err())
+			c.Stop()
+			c.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+	c.Stop()
+	c.Wait()
+}
+
+// idle reports whether the consumer has no task to run now
+func (c *Consumer) idle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.started || c.stopped || c.paused {
+		return true
+	}
+	return c.current == nil && c.queue.len() == 0
+}
+
+// Wait blocks until the goroutine of the stopped consumer exits
+func (c *Consumer) Wait() {
+	<-c.done
+}
+
+// discard discards all queued tasks of the stopped consumer
+func (c *Consumer) discard() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	log.Printf("stop consumer %s. %d tasks remains", c.ID, c.queue.len())
+	for _, task := range c.queue.remove(func(Task) bool { return true }) {
+		log.Printf("task %s is discarded. consumer will be killed", task.ID)
+	}
+}
+
+// loop runs tasks until ctx is done. it returns false if it panics
+func (c *Consumer) loop(ctx context.Context) (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("consumer %s panicked: %v\n%s", c.ID, err, debug.Stack())
+			ok = false
+		}
+	}()
+
+	for {
+		e, ok := c.next(ctx)
+		if !ok {
+			return true
+		}
+		log.Print("<-- task received: ", e.task.ID)
+		if err := c.run(ctx, e); err != nil {
+			log.Print("error occurs: ", err)
+		}
+		log.Print("task finished: ", e.task.ID)
+	}
+}
+
+// run runs task with context which is cancelled by Skip, preemption or TaskTimeout.
+// panic in the task is returned as error.
+func (c *Consumer) run(ctx context.Context, e entry) (err error) {
+	c.mu.Lock()
+	timeout := c.policy.TaskTimeout
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, consumerKey{}, c))
+	defer cancel()
+	timedOut := make(chan struct{})
+	if timeout > 0 {
+		go c.watchTimeout(ctx, timeout, func() {
+			close(timedOut)
+			cancel()
+		})
+	}
+
+	c.mu.Lock()
+	c.current = &e
+	c.cancelCurrent = cancel
+	c.preempted = false
+	c.skipped = false
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		if c.preempted {
+			log.Printf("<-- task %s of consumer %s is interrupted, requeue", e.task.ID, c.ID)
+			c.queue.requeue(e)
+		}
+		// skipped task ends normally
+		if c.skipped && errors.Is(err, context.Canceled) {
+			err = nil
+		}
+		c.current = nil
+		c.cancelCurrent = nil
+		c.preempted = false
+		c.skipped = false
+		c.mu.Unlock()
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.Add(metricPanics, 1)
+			err = fmt.Errorf("task %s panicked: %v\n%s", e.task.ID, r, debug.Stack())
+		}
+		select {
+		case <-timedOut:
+			metrics.Add(metricTimeouts, 1)
+			log.Printf("--x task %s of consumer %s timed out", e.task.ID, c.ID)
+			if errors.Is(err, context.Canceled) {
+				err = fmt.Errorf("task %s: %w", e.task.ID, context.DeadlineExceeded)
+			}
+		default:
+		}
+	}()
+
+	if e.task.Speech != nil {
+		return c.speak(ctx, e.task.Speech)
+	}
+	return e.task.Do(ctx)
+}
+
+// watchTimeout calls expire when the task of ctx runs for timeout excluding time the consumer is paused.
+// it returns when ctx is done
+func (c *Consumer) watchTimeout(ctx context.Context, timeout time.Duration, expire func()) {
+	remaining := timeout
+	for {
+		c.mu.Lock()
+		paused, resumed, pausing := c.paused, c.resumed, c.pausing
+		c.mu.Unlock()
+		if paused {
+			select {
+			case <-ctx.Done():
+				return
+			case <-resumed:
+			}
+			continue
+		}
+
+		start := time.Now()
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			expire()
+			return
+		case <-pausing:
+			timer.Stop()
+			remaining -= time.Since(start)
+		}
+	}
+}
+
+// next blocks until a task is queued and the consumer is not paused, and pops it.
+// ok is false if ctx is done
+func (c *Consumer) next(ctx context.Context) (entry, bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return entry{}, false
+		default:
+		}
+
+		c.mu.Lock()
+		if c.paused {
+			resumed := c.resumed
+			c.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return entry{}, false
+			case <-resumed:
+			}
+			continue
+		}
+		is, wait := c.ready()
+		if is == nil && wait > 0 {
+			c.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return entry{}, false
+			case <-c.notify:
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if is != nil {
+			if len(is) > 1 {
+				log.Printf("<-- merged %d tasks of consumer %s", len(is), c.ID)
 			}
-			log.Print("<-- task received: ", task.ID)
-			if err := task.Do(); err != nil {
-				log.Print("error occurs: ", err)
+			e := c.queue.take(is)
+			policy, depth := c.policy, c.queue.len()
+			c.mu.Unlock()
+			if sp := e.task.Speech; sp != nil {
+				age := time.Since(sp.EnqueuedAt)
+				if policy.MaxAge > 0 && age > policy.MaxAge {
+					log.Printf("--x skipped task %s of consumer %s. waited longer than %s", e.task.ID, c.ID, policy.MaxAge)
+					metrics.Add(metricDroppedStale, 1)
+					continue
+				}
+				// the speech may be read by Pending of other goroutines, so the rate is set to a copy
+				cp := *sp
+				cp.Rate = adaptiveRate(policy.MaxRate, depth, age)
+				e.task.Speech = &cp
 			}
-			log.Print("task finished: ", task.ID)
+			return e, true
 		}
+		c.mu.Unlock()
 
-		log.Printf("stop consumer %s. %d tasks remains", c.ID, len(c.queue))
-		close(c.queue)
-		for task := range c.queue {
-			log.Printf("task %s is discarded. consumer will be killed", task.ID)
+		select {
+		case <-ctx.Done():
+			return entry{}, false
+		case <-c.notify:
 		}
+	}
+}
 
-		log.Printf("consume %s is killed", c.ID)
-		wg.Done()
-	}()
+// ready returns indices of the next speeches to run at once in the schedule order.
+// speeches of authors waiting for following speeches to merge are passed over, so they don't block others.
+// if all queued speeches are waiting, is is nil and wait is the time until the first of them gets ready.
+// c.mu must be held.
+func (c *Consumer) ready() (is []int, wait time.Duration) {
+	waiting := map[string]bool{} // authors waiting for following speeches
+	for _, i := range c.queue.order(c.policy.Schedule) {
+		author := taskAuthor(c.queue.entries[i].task)
+		if waiting[author] {
+			continue
+		}
+		is := c.queue.coalescible(i, c.policy.CoalesceWindow)
+		w := c.coalesceWait(is)
+		if w <= 0 {
+			return is, 0
+		}
+		waiting[author] = true
+		if wait == 0 || w < wait {
+			wait = w
+		}
+	}
+	return nil, wait
 }
 
-func (c *Consumer) Add(t Task) {
+// coalesceWait returns how long to wait for following speeches which can be merged
+// to the speeches at indices is. c.mu must be held.
+func (c *Consumer) coalesceWait(is []int) time.Duration {
+	window := c.policy.CoalesceWindow
+	first := c.queue.entries[is[0]].task.Speech
+	if window <= 0 || first == nil || first.AuthorID == "" {
+		return 0
+	}
+	// speeches can be merged only if they are added one after another
+	lastEntry := c.queue.entries[is[len(is)-1]]
+	if lastEntry.seq != c.queue.seq {
+		return 0
+	}
+	last := lastEntry.task.Speech
+	deadline := last.EnqueuedAt.Add(window)
+	if limit := first.EnqueuedAt.Add(window * maxCoalesceWaitRatio); limit.Before(deadline) {
+		deadline = limit
+	}
+	return time.Until(deadline)
+}
+
+// SetPolicy updates the policy of the queue. tasks already queued are kept even if capacity is exceeded
+func (c *Consumer) SetPolicy(p Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policy = p
+}
+
+// Add queues t. speech of the message already queued is ignored.
+// ErrQueueFull is returned if t is discarded by the overflow policy, and ErrStopped if the consumer is stopped.
+func (c *Consumer) Add(t Task) error {
+	if t.Speech != nil && t.Speech.EnqueuedAt.IsZero() {
+		t.Speech.EnqueuedAt = time.Now()
+	}
+
+	c.mu.Lock()
+	if c.stopped || c.draining {
+		c.mu.Unlock()
+		log.Printf("--x discarded task %s. consumer %s is stopped", t.ID, c.ID)
+		return ErrStopped
+	}
+	if id := t.messageID(); id != "" && c.queue.find(func(q Task) bool { return q.messageID() == id }) >= 0 {
+		c.mu.Unlock()
+		log.Printf("--x discarded task %s of consumer %s. message %s is already queued", t.ID, c.ID, id)
+		return nil
+	}
+	if max := c.policy.MaxPerAuthor; max > 0 && t.Speech != nil && c.queue.countAuthor(t.Speech.AuthorID) >= max {
+		c.mu.Unlock()
+		log.Printf("--x discarded task %s of consumer %s. too many tasks of author %s are queued", t.ID, c.ID, t.Speech.AuthorID)
+		metrics.Add(metricDroppedAuthor, 1)
+		return ErrAuthorQueueFull
+	}
+	if c.queue.len() >= c.policy.Capacity {
+		if c.policy.Overflow != DropOldest || c.queue.len() == 0 {
+			c.mu.Unlock()
+			log.Printf("--x discarded task %+v of consumer %s. task queue is full", t, c.ID)
+			metrics.Add(metricDroppedOverflow, 1)
+			return ErrQueueFull
+		}
+		for c.queue.len() >= c.policy.Capacity {
+			old, ok := c.queue.popOldest()
+			if !ok {
+				break
+			}
+			log.Printf("--x discarded task %s of consumer %s. task queue is full", old.ID, c.ID)
+			metrics.Add(metricDroppedOverflow, 1)
+		}
+	}
+	c.queue.push(t)
+	if p := taskPriority(t); p >= PriorityInterrupt && c.current != nil && taskPriority(c.current.task) < p && !c.preempted {
+		log.Printf("--> task %s of consumer %s interrupts %s", t.ID, c.ID, c.current.task.ID)
+		c.preempted = true
+		c.cancelCurrent()
+	}
+	c.mu.Unlock()
+
 	select {
-	case c.queue <- t:
-		log.Printf("--> added task %+v to consumer %s", t, c.ID)
+	case c.notify <- struct{}{}:
 	default:
-		log.Printf("--x discarded task %+v of consumer %s. task queue is full", t, c.ID)
+	}
+	log.Printf("--> added task %+v to consumer %s", t, c.ID)
+	return nil
+}
+
+// Remove removes queued speech of the message and returns whether any task is removed.
+// the task already running is not affected.
+func (c *Consumer) Remove(messageID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := c.queue.remove(func(t Task) bool { return t.messageID() == messageID })
+	for _, t := range removed {
+		log.Printf("--x removed task %s of consumer %s", t.ID, c.ID)
+	}
+	return len(removed) > 0
+}
+
+// Replace replaces queued speech of the message with t keeping its position
+// and returns whether any task is replaced.
+func (c *Consumer) Replace(messageID string, t Task) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := c.queue.find(func(q Task) bool { return q.messageID() == messageID })
+	if i < 0 {
+		return false
+	}
+	old := &c.queue.entries[i]
+	if t.Speech != nil {
+		// edited message keeps waiting time
+		t.Speech.EnqueuedAt = old.task.Speech.EnqueuedAt
+	}
+	log.Printf("<-> replaced task %s with %s of consumer %s", old.task.ID, t.ID, c.ID)
+	old.task = t
+	return true
+}
+
+// Pending returns copy of queued tasks in the order to be run
+func (c *Consumer) Pending() []Task {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.queue.list(c.policy.Schedule)
+}
+
+// Current returns the running task
+func (c *Consumer) Current() (Task, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current == nil {
+		return Task{}, false
+	}
+	return c.current.task, true
+}
+
+// Skip cancels the running task and returns whether any task was running
+func (c *Consumer) Skip() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancelCurrent == nil {
+		return false
+	}
+	log.Printf("--x skip task %s of consumer %s", c.current.task.ID, c.ID)
+	c.preempted = false
+	c.skipped = true
+	c.cancelCurrent()
+	return true
+}
+
+// Clear removes all queued tasks and returns the number of removed tasks.
+// the task already running is not affected.
+func (c *Consumer) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.queue.remove(func(Task) bool { return true }))
+	log.Printf("--x cleared %d tasks of consumer %s", n, c.ID)
+	return n
+}
+
+// Pause pauses the consumer. the running task stops at the next call of WaitResumed
+func (c *Consumer) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		return
+	}
+	c.paused = true
+	c.resumed = make(chan struct{})
+	close(c.pausing)
+}
+
+// Resume resumes the paused consumer
+func (c *Consumer) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		return
+	}
+	c.paused = false
+	c.pausing = make(chan struct{})
+	close(c.resumed)
+}
+
+// Paused returns whether the consumer is paused
+func (c *Consumer) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// WaitResumed blocks while the consumer running the task is paused.
+// ctx must be the one passed to Task.Do. it returns ctx.Err() if ctx is done while waiting.
+func WaitResumed(ctx context.Context) error {
+	c, ok := ctx.Value(consumerKey{}).(*Consumer)
+	if !ok {
+		return ctx.Err()
+	}
+
+	c.mu.Lock()
+	paused, resumed := c.paused, c.resumed
+	c.mu.Unlock()
+	if !paused {
+		return ctx.Err()
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resumed:
+		return nil
 	}
 }
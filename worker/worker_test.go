@@ -0,0 +1,529 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTimeout = 5 * time.Second
+
+// recorder is Speaker recording texts read
+type recorder struct {
+	mu    sync.Mutex
+	texts []string
+	read  chan string
+}
+
+func newRecorder() *recorder {
+	return &recorder{read: make(chan string, 100)}
+}
+
+func (r *recorder) speak(ctx context.Context, s *Speech) error {
+	r.mu.Lock()
+	r.texts = append(r.texts, s.Text)
+	r.mu.Unlock()
+	r.read <- s.Text
+	return nil
+}
+
+func (r *recorder) wait(t *testing.T, n int) []string {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.read:
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out waiting for %d speeches", n)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.texts...)
+}
+
+// waitDone fails the test if the consumer doesn't exit soon
+func waitDone(t *testing.T, c *Consumer) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("consumer did not exit")
+	}
+}
+
+func TestAdaptiveRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxRate float64
+		depth   int
+		age     time.Duration
+		want    float64
+	}{
+		{"disabled", 1, 10, time.Minute, 1},
+		{"disabled by less than 1", 0, 10, time.Minute, 1},
+		{"no backlog", 2, 0, 0, 1},
+		{"raised by queued tasks", 2, 4, 0, 1.2},
+		{"raised by waiting time", 2, 0, 10 * time.Second, 1.1},
+		{"raised by both", 2, 4, 10 * time.Second, 1.3},
+		{"capped", 1.5, 100, time.Minute, 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := adaptiveRate(tt.maxRate, tt.depth, tt.age); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("adaptiveRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsumerSetsRateToCopy(t *testing.T) {
+	rates := make(chan float64, 1)
+	c := NewConsumer("test", func(ctx context.Context, s *Speech) error {
+		rates <- s.Rate
+		return nil
+	})
+	c.SetPolicy(Policy{Capacity: 10, Schedule: FIFO, MaxRate: 2})
+	task := speechTask("x", "a")
+	c.Add(task)
+	c.Start()
+	defer c.Stop()
+
+	select {
+	case rate := <-rates:
+		if rate < 1 {
+			t.Errorf("Rate = %v, want at least 1", rate)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("speech is not read")
+	}
+	if task.Speech.Rate != 0 {
+		t.Errorf("Rate of the queued speech = %v, want unchanged 0", task.Speech.Rate)
+	}
+}
+
+func TestConsumerRunsTasksInOrder(t *testing.T) {
+	r := newRecorder()
+	c := NewConsumer("test", r.speak)
+	c.SetPolicy(Policy{Capacity: 10, Schedule: FIFO})
+	for _, text := range []string{"a", "b", "c"} {
+		if err := c.Add(speechTask("x", text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Start()
+	defer c.Stop()
+
+	if got, want := r.wait(t, 3), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+}
+
+func TestConsumerStopWithEmptyQueue(t *testing.T) {
+	c := NewConsumer("test", newRecorder().speak)
+	c.Start()
+	c.Stop()
+	waitDone(t, c)
+}
+
+func TestConsumerStopWithoutStart(t *testing.T) {
+	c := NewConsumer("test", newRecorder().speak)
+	c.Stop()
+	c.Stop()
+	waitDone(t, c)
+}
+
+func TestConsumerAddAfterStop(t *testing.T) {
+	c := NewConsumer("test", newRecorder().speak)
+	c.Start()
+	c.Stop()
+	if err := c.Add(speechTask("x", "a")); !errors.Is(err, ErrStopped) {
+		t.Errorf("Add() = %v, want %v", err, ErrStopped)
+	}
+	waitDone(t, c)
+}
+
+func TestConsumerStopCancelsRunningTask(t *testing.T) {
+	started := make(chan struct{})
+	c := NewConsumer("test", newRecorder().speak)
+	c.Add(*NewTask("block", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	c.Start()
+	<-started
+	c.Stop()
+	waitDone(t, c)
+}
+
+// messageTask returns task to read text of the message
+func messageTask(messageID, text string) Task {
+	return *NewSpeechTask(&Speech{MessageID: messageID, AuthorID: "x", Text: text})
+}
+
+func TestConsumerRemove(t *testing.T) {
+	c := NewConsumer("test", newRecorder().speak)
+	c.SetPolicy(Policy{Capacity: 10, Schedule: FIFO})
+	c.Add(messageTask("m1", "a"))
+	c.Add(messageTask("m2", "b"))
+
+	if !c.Remove("m1") {
+		t.Error("Remove(m1) = false, want true")
+	}
+	if c.Remove("m1") {
+		t.Error("Remove(m1) for removed message = true, want false")
+	}
+	if got, want := texts(c.Pending()), []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Pending() = %v, want %v", got, want)
+	}
+}
+
+func TestConsumerReplace(t *testing.T) {
+	c := NewConsumer("test", newRecorder().speak)
+	c.SetPolicy(Policy{Capacity: 10, Schedule: FIFO})
+	c.Add(messageTask("m1", "a"))
+	c.Add(messageTask("m2", "b"))
+	enqueuedAt := c.Pending()[0].Speech.EnqueuedAt
+
+	if !c.Replace("m1", messageTask("m1", "edited")) {
+		t.Error("Replace(m1) = false, want true")
+	}
+	if c.Replace("m3", messageTask("m3", "c")) {
+		t.Error("Replace(m3) for message not queued = true, want false")
+	}
+	pending := c.Pending()
+	if got, want := texts(pending), []string{"edited", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Pending() = %v, want %v", got, want)
+	}
+	// edited message keeps its position and waiting time
+	if got := pending[0].Speech.EnqueuedAt; !got.Equal(enqueuedAt) {
+		t.Errorf("EnqueuedAt = %v, want %v", got, enqueuedAt)
+	}
+}
+
+func TestConsumerCoalesceDoesNotBlockOthers(t *testing.T) {
+	r := newRecorder()
+	c := NewConsumer("test", r.speak)
+	c.SetPolicy(Policy{Capacity: 10, Schedule: FIFO, CoalesceWindow: 300 * time.Millisecond})
+	c.Add(speechTask("a", "a1"))
+	c.Add(speechTask("b", "b1"))
+	c.Start()
+	defer c.Stop()
+
+	// a1 can't be merged with following speeches of a because b1 is between them, so it is read soon
+	select {
+	case text := <-r.read:
+		if text != "a1" {
+			t.Fatalf("read %s first, want a1", text)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("a1 waits for the window though b1 follows it")
+	}
+	if got, want := r.wait(t, 1), []string{"a1", "b1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+}
+
+func TestConsumerCoalesceWaitPassedOver(t *testing.T) {
+	r := newRecorder()
+	c := NewConsumer("test", r.speak)
+	c.SetPolicy(Policy{Capacity: 10, Schedule: FIFO, CoalesceWindow: 300 * time.Millisecond})
+	c.Add(speechTask("b", "b1"))
+	high := speechTask("a", "a1")
+	high.Speech.Priority = PriorityHigh
+	c.Add(high)
+	c.Start()
+	defer c.Stop()
+
+	// a1 is run first by priority but waits for following speeches of a, so b1 is read meanwhile
+	select {
+	case text := <-r.read:
+		if text != "b1" {
+			t.Fatalf("read %s first, want b1", text)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("b1 is blocked by coalescing of a")
+	}
+	if got, want := r.wait(t, 1), []string{"b1", "a1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+}
+
+func TestConsumerDrain(t *testing.T) {
+	r := newRecorder()
+	c := NewConsumer("test", func(ctx context.Context, s *Speech) error {
+		time.Sleep(10 * time.Millisecond)
+		return r.speak(ctx, s)
+	})
+	c.SetPolicy(Policy{Capacity: 10, Schedule: FIFO})
+	for _, text := range []string{"a", "b", "c"} {
+		c.Add(speechTask("x", text))
+	}
+	c.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	c.Drain(ctx)
+	if got, want := r.wait(t, 3), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+	if err := c.Add(speechTask("x", "d")); !errors.Is(err, ErrStopped) {
+		t.Errorf("Add() = %v, want %v", err, ErrStopped)
+	}
+}
+
+func TestConsumerDrainDeadline(t *testing.T) {
+	started := make(chan struct{})
+	c := NewConsumer("test", newRecorder().speak)
+	c.Add(*NewTask("block", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	c.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		c.Drain(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("Drain() did not return after deadline")
+	}
+}
+
+func TestConsumerConcurrentAddAndStop(t *testing.T) {
+	c := NewConsumer("test", func(ctx context.Context, s *Speech) error { return nil })
+	c.Start()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := c.Add(speechTask("x", "a")); errors.Is(err, ErrStopped) {
+					return
+				}
+			}
+		}()
+	}
+	c.Stop()
+	wg.Wait()
+	waitDone(t, c)
+}
+
+func TestConsumerRecoversPanic(t *testing.T) {
+	r := newRecorder()
+	c := NewConsumer("test", r.speak)
+	c.Add(*NewTask("panic", func(ctx context.Context) error { panic("oops") }))
+	c.Add(speechTask("x", "after panic"))
+	c.Start()
+	defer c.Stop()
+
+	if got, want := r.wait(t, 1), []string{"after panic"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+}
+
+func TestConsumerTaskTimeout(t *testing.T) {
+	r := newRecorder()
+	c := NewConsumer("test", r.speak)
+	c.SetPolicy(Policy{Capacity: 10, TaskTimeout: 10 * time.Millisecond})
+	c.Add(*NewTask("hang", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	c.Add(speechTask("x", "after hang"))
+	c.Start()
+	defer c.Stop()
+
+	if got, want := r.wait(t, 1), []string{"after hang"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+}
+
+func TestConsumerTaskTimeoutExcludesPause(t *testing.T) {
+	started := make(chan struct{})
+	paused := make(chan struct{})
+	errc := make(chan error, 1)
+	c := NewConsumer("test", newRecorder().speak)
+	c.SetPolicy(Policy{Capacity: 10, TaskTimeout: 100 * time.Millisecond})
+	c.Add(*NewTask("paused", func(ctx context.Context) error {
+		close(started)
+		<-paused
+		err := WaitResumed(ctx)
+		errc <- err
+		return err
+	}))
+	c.Start()
+	defer c.Stop()
+	<-started
+
+	// paused longer than the timeout
+	c.Pause()
+	close(paused)
+	select {
+	case err := <-errc:
+		t.Fatalf("task ended while paused: %v", err)
+	case <-time.After(250 * time.Millisecond):
+	}
+	c.Resume()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("WaitResumed() = %v, want nil", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("task did not end after resumed")
+	}
+}
+
+func TestConsumerSkipAndPause(t *testing.T) {
+	r := newRecorder()
+	started := make(chan struct{})
+	c := NewConsumer("test", r.speak)
+	c.SetPolicy(Policy{Capacity: 10, Schedule: FIFO})
+	c.Add(*NewTask("long", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	c.Start()
+	defer c.Stop()
+	<-started
+
+	c.Pause()
+	c.Add(speechTask("x", "a"))
+	if !c.Skip() {
+		t.Fatal("Skip() = false, want true")
+	}
+
+	select {
+	case text := <-r.read:
+		t.Fatalf("read %s while paused", text)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := len(c.Pending()); got != 1 {
+		t.Fatalf("len(Pending()) = %d, want 1", got)
+	}
+
+	c.Resume()
+	if got, want := r.wait(t, 1), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+}
+
+func TestConsumerSkipEndsTaskNormally(t *testing.T) {
+	started := make(chan struct{})
+	c := NewConsumer("test", newRecorder().speak)
+	e := entry{task: *NewTask("long", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})}
+	errc := make(chan error, 1)
+	go func() { errc <- c.run(context.Background(), e) }()
+	<-started
+
+	if !c.Skip() {
+		t.Fatal("Skip() = false, want true")
+	}
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("run() of skipped task = %v, want nil", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("skipped task did not end")
+	}
+	if c.Skip() {
+		t.Error("Skip() while idle = true, want false")
+	}
+}
+
+func TestConsumerPauseResume(t *testing.T) {
+	c := NewConsumer("test", newRecorder().speak)
+	if c.Paused() {
+		t.Fatal("new consumer should not be paused")
+	}
+	c.Pause()
+	c.Pause()
+	if !c.Paused() {
+		t.Fatal("Paused() = false after Pause()")
+	}
+
+	// WaitResumed in tasks of the consumer blocks until resumed
+	ctx := context.WithValue(context.Background(), consumerKey{}, c)
+	done := make(chan error, 1)
+	go func() { done <- WaitResumed(ctx) }()
+	select {
+	case <-done:
+		t.Fatal("WaitResumed() returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.Resume()
+	c.Resume()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WaitResumed() = %v, want nil", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("WaitResumed() did not return after Resume()")
+	}
+	if c.Paused() {
+		t.Error("Paused() = true after Resume()")
+	}
+}
+
+func TestConsumerOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow Overflow
+		wantErr  error
+		want     []string
+	}{
+		{
+			name:     "drop newest should reject the added task",
+			overflow: DropNewest,
+			wantErr:  ErrQueueFull,
+			want:     []string{"a", "b"},
+		},
+		{
+			name:     "drop oldest should discard the oldest task",
+			overflow: DropOldest,
+			wantErr:  nil,
+			want:     []string{"b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConsumer("test", newRecorder().speak)
+			c.SetPolicy(Policy{Capacity: 2, Overflow: tt.overflow, Schedule: FIFO})
+			c.Add(speechTask("x", "a"))
+			c.Add(speechTask("x", "b"))
+			if err := c.Add(speechTask("x", "c")); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Add() = %v, want %v", err, tt.wantErr)
+			}
+			if got := texts(c.Pending()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pending() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}